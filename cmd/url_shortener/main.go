@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,7 +10,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/voukatas/url-shortener/internal/analytics"
 	"github.com/voukatas/url-shortener/internal/config"
+	"github.com/voukatas/url-shortener/internal/model"
 	"github.com/voukatas/url-shortener/internal/server"
 	"github.com/voukatas/url-shortener/internal/store"
 	"github.com/voukatas/url-shortener/internal/url_converter"
@@ -27,15 +30,38 @@ func main() {
 
 	fmt.Printf("Loaded config: %+v\n", config)
 
-	// init obfuscation
-	url_converter.InitBase62Array(config.ShuffleKey)
+	// schema management instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(config, os.Args[2:]); err != nil {
+			fmt.Printf("Migrate failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// short codes
+	alphabet, err := url_converter.AlphabetByName(config.Alphabet)
+	if err != nil {
+		fmt.Printf("Codec init failed: %v\n", err)
+		return
+	}
+	converter, err := newConverter(config, alphabet)
+	if err != nil {
+		fmt.Printf("Codec init failed: %v\n", err)
+		return
+	}
+	randomCodes, err := newRandomCodes(config, alphabet, converter)
+	if err != nil {
+		fmt.Printf("Codec init failed: %v\n", err)
+		return
+	}
 
 	// logging
 	slogger, cleanup := logger.SetupLogger(config.LogFilename, config.LogLevel, config.Production)
 	defer cleanup()
 
 	// store
-	store, err := store.NewStore(config.DBFilename + "?mode=rwc")
+	store, err := newStore(config)
 	if err != nil {
 		slogger.Error("Store init", "error", err.Error())
 		return
@@ -47,9 +73,24 @@ func main() {
 	store.SetStoreOptions()
 
 	// cache
-	cache := cache.NewCache(config.CacheCapacity)
+	cache, err := cache.NewCache(cache.Options{
+		Capacity: config.CacheCapacity,
+		TTL:      time.Duration(config.CacheTTLSeconds) * time.Second,
+		Policy:   config.CachePolicy,
+		Shards:   config.CacheShards,
+	})
+	if err != nil {
+		slogger.Error("Cache init", "error", err.Error())
+		return
+	}
+	defer cache.Stop()
 
-	server := server.NewServer(store, http.NewServeMux(), config, slogger, cache)
+	// click analytics
+	clicks := analytics.NewRecorder(store, slogger, config.ClickQueueSize)
+
+	server := server.NewServer(store, http.NewServeMux(), config, slogger, cache, converter)
+	server.Clicks = clicks
+	server.RandomCodes = randomCodes
 	server.SetupHandlers()
 
 	httpServer := &http.Server{
@@ -81,5 +122,125 @@ func main() {
 		server.Logger.Error("Server Shutdown Failed", "error", err)
 	}
 
+	// flush the queued clicks before the store is closed
+	clicks.Close()
+	server.Logger.Error("Click recorder stopped", "dropped", clicks.Dropped())
+
+	if randomCodes != nil {
+		metrics := randomCodes.Metrics()
+		server.Logger.Error("Random codes", "generated", metrics.Generated, "collisions", metrics.Collisions,
+			"collision_rate", metrics.CollisionRate(), "rejected", metrics.Rejected)
+	}
+
 	server.Logger.Error("Server exited normally")
 }
+
+// newConverter creates the codec of the config, wrapped in a keyring if keys are configured
+// and with a check character if it is turned on
+func newConverter(config *model.Config, alphabet *url_converter.Alphabet) (url_converter.Converter, error) {
+	converter, err := newKeyring(config, alphabet)
+	if err != nil || !config.CheckChar {
+		return converter, err
+	}
+	return url_converter.NewChecked(alphabet, converter), nil
+}
+
+// newRandomCodes generates codes in the alphabet of the converter, with its check character if it has one,
+// and rejects any code the converter would decode to an ID. Codes are stored in the spelling of the alphabet.
+func newRandomCodes(config *model.Config, alphabet *url_converter.Alphabet, converter url_converter.Converter) (*store.RandomCodes, error) {
+	length := config.RandomCodeLength
+	if length == 0 {
+		length = url_converter.DefaultRandomCodeLength
+	}
+	// shorter codes could be guessed, longer ones would not pass as an alias. Without random_codes
+	// only the requests asking for a random code fail.
+	if length < 8 || length > 64 {
+		err := fmt.Errorf("random code length must be between 8 and 64, got %d", length)
+		if config.RandomCodes {
+			return nil, err
+		}
+		fmt.Printf("Random codes not available: %v\n", err)
+		return nil, nil
+	}
+
+	generate := func() (string, error) { return alphabet.Random(length) }
+	if checked, ok := converter.(*url_converter.Checked); ok {
+		generate = func() (string, error) { return checked.Random(length) }
+	}
+	reject := func(code string) bool {
+		_, err := converter.Decode(code)
+		return err == nil
+	}
+	return store.NewRandomCodes(generate, reject, alphabet.Normalize), nil
+}
+
+func newKeyring(config *model.Config, alphabet *url_converter.Alphabet) (url_converter.Converter, error) {
+	legacyKey := model.CodecKey{XorSecretKey: config.XorSecretKey, ShuffleKey: config.ShuffleKey, CodecKey: config.CodecKey}
+	if len(config.Keys) == 0 {
+		return newCodec(config, alphabet, legacyKey, config.MinCodeLength)
+	}
+
+	// codes from before the keyring, a feistel codec without a top level key never made any
+	var legacy url_converter.Converter
+	if config.Codec != "feistel" || config.CodecKey != "" {
+		var err error
+		if legacy, err = newCodec(config, alphabet, legacyKey, config.MinCodeLength); err != nil {
+			return nil, err
+		}
+	}
+
+	// versioned codes are told from legacy ones by their length, feistel codes already are longer
+	minLength := config.MinCodeLength
+	if legacy != nil && config.Codec != "feistel" {
+		minLength = alphabet.MaxLength()
+	}
+
+	current := config.CurrentKeyVersion
+	versions := map[int]url_converter.Converter{}
+	for _, key := range config.Keys {
+		if _, exists := versions[key.Version]; exists {
+			return nil, fmt.Errorf("key version %d is configured twice", key.Version)
+		}
+		converter, err := newCodec(config, alphabet, key, minLength)
+		if err != nil {
+			return nil, fmt.Errorf("key version %d: %w", key.Version, err)
+		}
+		versions[key.Version] = converter
+		if config.CurrentKeyVersion == 0 && key.Version > current {
+			current = key.Version
+		}
+	}
+
+	return url_converter.NewKeyring(alphabet, current, versions, legacy)
+}
+
+func newCodec(config *model.Config, alphabet *url_converter.Alphabet, key model.CodecKey, minLength int) (url_converter.Converter, error) {
+	switch config.Codec {
+	case "", "xor":
+		return url_converter.NewPaddedCodec(alphabet, key.ShuffleKey, key.XorSecretKey, minLength)
+	case "feistel":
+		if minLength > 0 {
+			return nil, errors.New("min_code_length does not apply to the feistel codec, its codes have a fixed width")
+		}
+		bits := config.CodecBits
+		if bits == 0 {
+			bits = url_converter.DefaultFeistelBits
+		}
+		return url_converter.NewFeistelCodec(alphabet, key.CodecKey, bits)
+	default:
+		return nil, fmt.Errorf("unknown codec %q", config.Codec)
+	}
+}
+
+func newStore(config *model.Config) (store.Store, error) {
+	switch config.DBDriver {
+	case "", "sqlite3":
+		return store.NewStore(config.DBFilename + "?mode=rwc")
+	case "postgres":
+		return store.NewPostgresStore(config.DBDataSource)
+	case "memory":
+		return store.NewMemoryStore(config.SnapshotFilename)
+	default:
+		return nil, fmt.Errorf("unknown db_driver %q", config.DBDriver)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/voukatas/url-shortener/internal/model"
+	"github.com/voukatas/url-shortener/internal/store"
+)
+
+const migrateUsage = `usage: url_shortener migrate [-dry-run] [-to version] up|down|status
+
+  up      apply pending migrations, up to -to if set (default latest)
+  down    revert migrations down to -to (default one version below current)
+  status  print the current and latest schema version and the pending migrations`
+
+// runMigrate manages the schema of the SQLite database configured in db_filename
+func runMigrate(config *model.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), migrateUsage) }
+	dryRun := flags.Bool("dry-run", false, "print the SQL without applying it")
+	to := flags.Int("to", -1, "target schema version")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one of up, down or status")
+	}
+
+	if config.DBDriver != "" && config.DBDriver != "sqlite3" {
+		return fmt.Errorf("migrations are only supported for sqlite3, not %q", config.DBDriver)
+	}
+
+	db, err := sql.Open("sqlite3", config.DBFilename+"?mode=rwc")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator := store.NewMigrator(db, os.Stdout, *dryRun)
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		target := *to
+		if target < 0 {
+			target = store.LatestVersion()
+		}
+		return migrator.Up(target)
+	case "down":
+		target := *to
+		if target < 0 {
+			target = version - 1
+		}
+		if target < 0 {
+			return errors.New("nothing to revert")
+		}
+		return migrator.Down(target)
+	case "status":
+		pending, err := migrator.Pending(store.LatestVersion())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("current version: %d\nlatest version: %d\n", version, store.LatestVersion())
+		for _, migration := range pending {
+			fmt.Printf("pending: %d %s\n", migration.Version, migration.Name)
+		}
+		return nil
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command %q", flags.Arg(0))
+	}
+}
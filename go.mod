@@ -2,4 +2,7 @@ module github.com/voukatas/url-shortener
 
 go 1.22.4
 
-require github.com/mattn/go-sqlite3 v1.14.24
+require (
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.24
+)
@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/voukatas/url-shortener/internal/store"
+	"github.com/voukatas/url-shortener/pkg/logger"
+)
+
+const (
+	DefaultQueueSize = 10000
+	maxBatchSize     = 100
+)
+
+// Recorder writes clicks to the store from a single background worker so redirects never wait on the DB.
+// When the queue is full new clicks are dropped and counted instead of blocking the caller.
+type Recorder struct {
+	store   store.Store
+	logger  logger.Logger
+	queue   chan store.Click
+	dropped atomic.Uint64
+	wg      sync.WaitGroup
+	// closed is guarded by lock, redirects still running after a shutdown timeout may record clicks
+	lock   sync.RWMutex
+	closed bool
+}
+
+func NewRecorder(db store.Store, logger logger.Logger, queueSize int) *Recorder {
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
+	}
+
+	recorder := &Recorder{
+		store:  db,
+		logger: logger,
+		queue:  make(chan store.Click, queueSize),
+	}
+
+	recorder.wg.Add(1)
+	go recorder.run()
+
+	return recorder
+}
+
+// Record queues a click without blocking, a click recorded after Close is dropped
+func (recorder *Recorder) Record(click store.Click) {
+	recorder.lock.RLock()
+	defer recorder.lock.RUnlock()
+
+	if recorder.closed {
+		recorder.dropped.Add(1)
+		return
+	}
+	select {
+	case recorder.queue <- click:
+	default:
+		recorder.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of clicks discarded because the queue was full or the recorder closed
+func (recorder *Recorder) Dropped() uint64 {
+	return recorder.dropped.Load()
+}
+
+// Close stops accepting clicks and waits until the queued ones are written
+func (recorder *Recorder) Close() {
+	recorder.lock.Lock()
+	if !recorder.closed {
+		recorder.closed = true
+		close(recorder.queue)
+	}
+	recorder.lock.Unlock()
+
+	recorder.wg.Wait()
+}
+
+func (recorder *Recorder) run() {
+	defer recorder.wg.Done()
+
+	var reportedDrops uint64
+	batch := make([]store.Click, 0, maxBatchSize)
+
+	for click := range recorder.queue {
+		batch = append(batch, click)
+
+		// take whatever else is already waiting, up to a full batch
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case click, ok := <-recorder.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, click)
+			default:
+				break drain
+			}
+		}
+
+		if err := recorder.store.RecordClicks(batch); err != nil {
+			recorder.logger.Error("RecordClicks", "error", err, "clicks", len(batch))
+		}
+		batch = batch[:0]
+
+		if dropped := recorder.Dropped(); dropped != reportedDrops {
+			recorder.logger.Warn("Click queue full, clicks dropped", "dropped", dropped)
+			reportedDrops = dropped
+		}
+	}
+}
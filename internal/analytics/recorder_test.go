@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/voukatas/url-shortener/internal/store"
+)
+
+// mock db, only RecordClicks is used by the recorder
+type mockStore struct {
+	store.Store
+	lock    sync.Mutex
+	clicks  []store.Click
+	blocked chan struct{}
+}
+
+func (s *mockStore) RecordClicks(clicks []store.Click) error {
+	if s.blocked != nil {
+		<-s.blocked
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+// mock logger
+type mockLogger struct {
+}
+
+// No Operation
+func (s *mockLogger) Debug(msg string, args ...interface{}) {}
+func (s *mockLogger) Info(msg string, args ...interface{})  {}
+func (s *mockLogger) Warn(msg string, args ...interface{})  {}
+func (s *mockLogger) Error(msg string, args ...interface{}) {}
+
+func TestRecorderWritesAllClicksOnClose(t *testing.T) {
+	mStore := &mockStore{}
+	recorder := NewRecorder(mStore, &mockLogger{}, 1000)
+
+	for i := 0; i < 500; i++ {
+		recorder.Record(store.Click{UrlID: int64(i)})
+	}
+	recorder.Close()
+
+	if len(mStore.clicks) != 500 {
+		t.Errorf("expected %v received %v", 500, len(mStore.clicks))
+	}
+	if recorder.Dropped() != 0 {
+		t.Errorf("expected %v received %v", 0, recorder.Dropped())
+	}
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	mStore := &mockStore{blocked: make(chan struct{})}
+	recorder := NewRecorder(mStore, &mockLogger{}, 10)
+
+	// the worker holds at most one batch while blocked, the rest has to fit in the queue
+	for i := 0; i < 200; i++ {
+		recorder.Record(store.Click{UrlID: int64(i)})
+	}
+	close(mStore.blocked)
+	recorder.Close()
+
+	if recorder.Dropped() == 0 {
+		t.Error("expected clicks to be dropped")
+	}
+	if uint64(len(mStore.clicks))+recorder.Dropped() != 200 {
+		t.Errorf("expected %v recorded and dropped clicks received %v", 200, uint64(len(mStore.clicks))+recorder.Dropped())
+	}
+}
+
+func TestRecorderDropsAfterClose(t *testing.T) {
+	mStore := &mockStore{}
+	recorder := NewRecorder(mStore, &mockLogger{}, 10)
+	recorder.Close()
+
+	// a redirect that outlived the shutdown
+	recorder.Record(store.Click{UrlID: 1})
+	recorder.Close()
+
+	if len(mStore.clicks) != 0 || recorder.Dropped() != 1 {
+		t.Errorf("expected the click to be dropped, received %v clicks and %v dropped", len(mStore.clicks), recorder.Dropped())
+	}
+}
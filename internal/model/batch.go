@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// BatchResult is the outcome of one url of a batch request, Error and its problem Code are set
+// instead of ShortUrl if it was not created
+type BatchResult struct {
+	Index     int        `json:"index"`
+	LongUrl   string     `json:"long_url"`
+	ShortUrl  string     `json:"short_url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// the url already had a link, which is returned instead of a new one
+	Existing bool   `json:"existing,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+type BatchResponse struct {
+	Created  int           `json:"created"`
+	Existing int           `json:"existing"`
+	Failed   int           `json:"failed"`
+	Results  []BatchResult `json:"results"`
+}
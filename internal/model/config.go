@@ -1,12 +1,54 @@
 package model
 
 type Config struct {
-	Address       string `json:"address"`
-	DBFilename    string `json:"db_filename"`
-	LogFilename   string `json:"log_filename"`
-	XorSecretKey  int64  `json:"xor_secret_key"`
-	ShuffleKey    string `json:"shuffle_key"`
-	LogLevel      string `json:"log_level"`
-	Production    bool   `json:"production"`
-	CacheCapacity int    `json:"cache_capacity"`
+	Address    string `json:"address"`
+	DBFilename string `json:"db_filename"`
+	// "sqlite3" (default), "postgres" which connects to DBDataSource,
+	// or "memory" which is optionally persisted to SnapshotFilename
+	DBDriver         string `json:"db_driver"`
+	DBDataSource     string `json:"db_dsn"`
+	SnapshotFilename string `json:"snapshot_filename"`
+	LogFilename      string `json:"log_filename"`
+	XorSecretKey     int64  `json:"xor_secret_key"`
+	ShuffleKey       string `json:"shuffle_key"`
+	// "xor" (default) for the XOR and shuffled base62 codes, or "feistel" for fixed-width
+	// codes encrypted with CodecKey over CodecBits bits of ID
+	Codec     string `json:"codec"`
+	CodecKey  string `json:"codec_key"`
+	CodecBits int    `json:"codec_bits"`
+	// "base62" (default), "base36", "crockford32" or "base57", the characters of the codes
+	Alphabet string `json:"alphabet"`
+	// pads shorter xor codes to this length, the length of the largest ID makes every code the same length
+	MinCodeLength int `json:"min_code_length"`
+	// appends a check character to every code, so mistyped codes are rejected without a lookup
+	CheckChar bool `json:"check_char"`
+	// optional key rotation, new codes start with the version of the current key and the keys
+	// above only decode the codes made before. CurrentKeyVersion 0 means the highest version.
+	Keys              []CodecKey `json:"keys"`
+	CurrentKeyVersion int        `json:"current_key_version"`
+	LogLevel          string     `json:"log_level"`
+	Production        bool       `json:"production"`
+	CacheCapacity     int        `json:"cache_capacity"`
+	// cached redirects are looked up again after this many seconds, 0 keeps them until they are evicted
+	CacheTTLSeconds int `json:"cache_ttl_seconds"`
+	// eviction policy of the cache, "lru" (default), "lfu", "2q", "arc" or "tinylfu"
+	CachePolicy string `json:"cache_policy"`
+	// splits the cache into independently locked shards, 0 or 1 keeps a single lock
+	CacheShards int `json:"cache_shards"`
+	// new links get a random code of RandomCodeLength characters instead of the code of their ID,
+	// a request can override it
+	RandomCodes      bool `json:"random_codes"`
+	RandomCodeLength int  `json:"random_code_length"`
+	// reuse the existing short code when the same url is posted again, a request can override it
+	Dedup bool `json:"dedup"`
+	// size of the click analytics queue, clicks beyond it are dropped
+	ClickQueueSize int `json:"click_queue_size"`
+}
+
+// CodecKey is one version of the keys of the codec, only the keys of the configured codec are used
+type CodecKey struct {
+	Version      int    `json:"version"`
+	XorSecretKey int64  `json:"xor_secret_key"`
+	ShuffleKey   string `json:"shuffle_key"`
+	CodecKey     string `json:"codec_key"`
 }
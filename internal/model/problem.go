@@ -0,0 +1,14 @@
+package model
+
+// Problem is the RFC 7807 body of every error response. Code is a stable machine-readable
+// error code, Type is the same code as a URI and Title the HTTP status text. Suggestions are
+// the short codes a mistyped one was probably meant to be.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+
+	Suggestions []string `json:"suggestions,omitempty"`
+}
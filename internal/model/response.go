@@ -1,7 +1,22 @@
 package model
 
+import "time"
+
 type ShortUrlResponse struct {
 	//Key      string `json:"key"`
-	LongUrl  string `json:"long_url"`
-	ShortUrl string `json:"short_url"`
+	LongUrl   string     `json:"long_url"`
+	ShortUrl  string     `json:"short_url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type Revision struct {
+	LongUrl   string    `json:"long_url"`
+	ChangedAt time.Time `json:"changed_at"`
+	Actor     string    `json:"actor"`
+}
+
+type HistoryResponse struct {
+	ShortUrl string     `json:"short_url"`
+	LongUrl  string     `json:"long_url"`
+	History  []Revision `json:"history"`
 }
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type StatsBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+type StatsCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+type StatsResponse struct {
+	ShortUrl       string        `json:"short_url"`
+	LongUrl        string        `json:"long_url"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Hourly         []StatsBucket `json:"hourly"`
+	Daily          []StatsBucket `json:"daily"`
+	TopReferrers   []StatsCount  `json:"top_referrers"`
+	TopUserAgents  []StatsCount  `json:"top_user_agents"`
+}
+
+// RandomCodeMetricsResponse counts the random codes generated since the service started
+type RandomCodeMetricsResponse struct {
+	Generated     uint64  `json:"generated"`
+	Collisions    uint64  `json:"collisions"`
+	CollisionRate float64 `json:"collision_rate"`
+	Rejected      uint64  `json:"rejected"`
+}
@@ -1,5 +1,22 @@
 package model
 
+import "time"
+
 type Url struct {
 	Url string `json:"url"`
+	// optional expiry, either an absolute time or a lifetime in seconds
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	// optional vanity code used instead of the generated one
+	Alias string `json:"alias,omitempty"`
+	// overrides the dedup setting of the config for this request
+	Dedup *bool `json:"dedup,omitempty"`
+	// overrides the random_codes setting of the config for this request
+	Random *bool `json:"random,omitempty"`
+}
+
+type UrlUpdate struct {
+	Url string `json:"url"`
+	// who made the change, defaults to the client address
+	Actor string `json:"actor,omitempty"`
 }
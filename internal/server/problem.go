@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/voukatas/url-shortener/internal/model"
+	"github.com/voukatas/url-shortener/internal/store"
+)
+
+// The machine-readable codes of the problem responses, clients rely on them so they never change
+const (
+	codeInvalidBody      = "invalid_body"
+	codeMissingShortUrl  = "missing_short_url"
+	codeInvalidShortCode = "invalid_short_code"
+	codeMissingProtocol  = "missing_protocol"
+	codeInvalidExpiry    = "invalid_expiry"
+	codeInvalidAlias     = "invalid_alias"
+	codeInvalidQuery     = "invalid_query"
+	codeInvalidBatch     = "invalid_batch"
+	codeTooLarge         = "request_too_large"
+	codeNotFound         = "not_found"
+	codeMistyped         = "mistyped_short_code"
+	codeExpired          = "expired"
+	codeDisabled         = "disabled"
+	codeAliasTaken       = "alias_taken"
+	codeConflict         = "conflict"
+	codeCodesExhausted   = "codes_exhausted"
+	codeInternal         = "internal_error"
+)
+
+const problemContentType = "application/problem+json"
+
+// requestError is an invalid request, it is reported with its own code and detail
+type requestError struct {
+	code   string
+	detail string
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func badRequest(code string, detail string) error {
+	return &requestError{code: code, detail: detail}
+}
+
+// mistypedError is a code that fails its check character, answered as not found with the codes
+// it was probably meant to be
+type mistypedError struct {
+	code        string
+	suggestions []string
+}
+
+func (e *mistypedError) Error() string {
+	return "short code " + e.code + " is mistyped"
+}
+
+// storeProblems are the responses to the store errors, checked in order with errors.Is
+var storeProblems = []struct {
+	err    error
+	status int
+	code   string
+	detail string
+}{
+	{store.ErrNotFound, http.StatusNotFound, codeNotFound, "Short URL does not exist"},
+	{store.ErrExpired, http.StatusGone, codeExpired, "Short URL has expired"},
+	{store.ErrDisabled, http.StatusGone, codeDisabled, "Short URL is disabled"},
+	{store.ErrAliasTaken, http.StatusConflict, codeAliasTaken, "Alias already in use"},
+	{store.ErrConflict, http.StatusConflict, codeConflict, "Conflict"},
+	{store.ErrCodesExhausted, http.StatusInsufficientStorage, codeCodesExhausted, "No short codes left for new links"},
+}
+
+// newProblem fills in the fields that follow from the status and code
+func newProblem(status int, code string, detail string) model.Problem {
+	return model.Problem{
+		Type:   "urn:url-shortener:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// problemFor describes an error for the client, errors that are neither a requestError nor
+// a known store error are internal and their details are not exposed
+func problemFor(err error) model.Problem {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return newProblem(http.StatusBadRequest, reqErr.code, reqErr.detail)
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newProblem(http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+	}
+
+	var mistyped *mistypedError
+	if errors.As(err, &mistyped) {
+		problem := newProblem(http.StatusNotFound, codeMistyped, "Short URL does not exist, it is probably mistyped")
+		problem.Suggestions = mistyped.suggestions
+		return problem
+	}
+
+	for _, known := range storeProblems {
+		if errors.Is(err, known.err) {
+			return newProblem(known.status, known.code, known.detail)
+		}
+	}
+
+	return newProblem(http.StatusInternalServerError, codeInternal, "Internal Server Error")
+}
+
+// writeError answers a failed request with the problem for err, only internal errors are logged as errors
+func (server *URLShortener) writeError(w http.ResponseWriter, handler string, err error) {
+	problem := problemFor(err)
+	if problem.Status == http.StatusInternalServerError {
+		server.Logger.Error(handler, "error", err)
+	} else {
+		server.Logger.Info(handler, "error", err)
+	}
+	writeProblem(w, problem)
+}
+
+func writeProblem(w http.ResponseWriter, problem model.Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -1,10 +1,18 @@
 package server
 
 import (
-	"database/sql"
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/voukatas/url-shortener/internal/model"
 	"github.com/voukatas/url-shortener/internal/store"
@@ -13,27 +21,47 @@ import (
 	"github.com/voukatas/url-shortener/pkg/logger"
 )
 
+// ClickRecorder receives every successful redirect, it must not block
+type ClickRecorder interface {
+	Record(store.Click)
+}
+
 type URLShortener struct {
 	Store  store.Store
 	Router *http.ServeMux
 	Config *model.Config
 	Logger logger.Logger
 	Cache  cache.Cache
+	// turns link IDs into short codes and back
+	Converter url_converter.Converter
+	// optional, clicks are not recorded if nil
+	Clicks ClickRecorder
+	// optional, links cannot get random codes if nil
+	RandomCodes *store.RandomCodes
 }
 
-func NewServer(store store.Store, router *http.ServeMux, config *model.Config, logger logger.Logger, cache cache.Cache) *URLShortener {
+func NewServer(store store.Store, router *http.ServeMux, config *model.Config, logger logger.Logger, cache cache.Cache, converter url_converter.Converter) *URLShortener {
 	return &URLShortener{
-		Store:  store,
-		Router: router,
-		Config: config,
-		Logger: logger,
-		Cache:  cache,
+		Store:     store,
+		Router:    router,
+		Config:    config,
+		Logger:    logger,
+		Cache:     cache,
+		Converter: converter,
 	}
 }
 
 func (server *URLShortener) SetupHandlers() {
 	server.Router.HandleFunc("GET /short/get/{url}", server.RedirectURL)
 	server.Router.HandleFunc("POST /short/post", server.CreateShortURL)
+	server.Router.HandleFunc("POST /short/batch", server.CreateShortURLBatch)
+	server.Router.HandleFunc("DELETE /short/{url}", server.DeleteShortURL)
+	server.Router.HandleFunc("PATCH /short/{url}", server.UpdateShortURL)
+	server.Router.HandleFunc("GET /short/history/{url}", server.GetHistory)
+	server.Router.HandleFunc("GET /short/stats/{url}", server.GetStats)
+	server.Router.HandleFunc("GET /short/metrics/random", server.GetRandomCodeMetrics)
+	server.Router.HandleFunc("POST /short/disable/{url}", server.DisableShortURL)
+	server.Router.HandleFunc("POST /short/enable/{url}", server.EnableShortURL)
 }
 
 func (server *URLShortener) RedirectURL(w http.ResponseWriter, r *http.Request) {
@@ -41,80 +69,452 @@ func (server *URLShortener) RedirectURL(w http.ResponseWriter, r *http.Request)
 	shortUrl := r.PathValue("url")
 	server.Logger.Debug("RedirectURL", "url", shortUrl, "address", server.getClientIP(r))
 
-	if shortUrl == "" {
-		http.Error(w, "Bad Request: Missing or invalid URL", http.StatusBadRequest)
-		server.Logger.Warn("RedirectURL Bad Request: Missing or invalid URL")
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "RedirectURL", err)
 		return
 	}
 
 	// retrieve value from cache
-	if url, err := server.Cache.Get(shortUrl); err == nil {
-		server.Logger.Info("RedirectURL - Cache Get found", "url", url)
-		http.Redirect(w, r, url, http.StatusFound)
+	cacheKey := server.cacheKey(shortUrl)
+	if value, err := server.Cache.Get(cacheKey); err == nil {
+		link := decodeCacheValue(value)
+		if err := link.Check(time.Now()); err != nil {
+			server.Logger.Info("RedirectURL - Cache Get expired", "url", link.LongUrl, "expires_at", link.ExpiresAt)
+			server.writeError(w, "RedirectURL", err)
+			return
+		}
+		server.Logger.Info("RedirectURL - Cache Get found", "url", link.LongUrl)
+		server.recordClick(r, shortUrl, link.ID)
+		http.Redirect(w, r, link.LongUrl, http.StatusFound)
 		return
 
 	}
 
-	decodedID := url_converter.DecodeShortCode(shortUrl, server.Config.XorSecretKey)
-	server.Logger.Info("RedirectURL", "Decoded ID", decodedID)
-
-	longUrl, err := server.Store.Lookup(decodedID)
+	link, byAlias, err := server.resolveLink(shortUrl)
+	if err == nil {
+		err = link.Check(time.Now())
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
-			return
-		}
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		server.Logger.Error("DecodeShortCode", "error", err)
+		server.writeError(w, "RedirectURL", err)
 		return
 	}
 
 	// store it in cache
-	server.Cache.Set(shortUrl, longUrl)
-	server.Logger.Info("RedirectURL - Cache Set triggered", "shortUrl", shortUrl, "longUrl", longUrl)
+	server.cacheLink(shortUrl, cacheKey, link, byAlias)
+	server.Logger.Info("RedirectURL - Cache Set triggered", "shortUrl", shortUrl, "longUrl", link.LongUrl)
 
-	http.Redirect(w, r, longUrl, http.StatusFound)
+	server.recordClick(r, shortUrl, link.ID)
+	http.Redirect(w, r, link.LongUrl, http.StatusFound)
 }
 
 func (server *URLShortener) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	var url model.Url
 	if err := json.NewDecoder(r.Body).Decode(&url); err != nil {
 		server.Logger.Error("Failed to decode JSON", "error", err, "address", server.getClientIP(r))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, newProblem(http.StatusBadRequest, codeInvalidBody, "Invalid request body"))
 		return
 	}
 	defer r.Body.Close()
 
-	if !strings.HasPrefix(url.Url, "http://") && !strings.HasPrefix(url.Url, "https://") {
-		server.Logger.Error("Missing Protocol", "address", server.getClientIP(r))
-		http.Error(w, "Protocol Missing", http.StatusBadRequest)
+	link, err := server.linkFromRequest(url, time.Now())
+	if err != nil {
+		server.writeError(w, "CreateShortURL invalid request", err)
 		return
-
 	}
 
-	id, err := server.Store.Shorten(url.Url)
+	// a link with a random code is always a new link
+	var id int64
+	created := true
+	switch {
+	case link.Random:
+		id, link.Alias, err = server.RandomCodes.Shorten(server.Store, link)
+	case server.dedup(url):
+		id, created, err = server.Store.ShortenDedup(link)
+	default:
+		id, err = server.Store.Shorten(link)
+	}
 	if err != nil {
-		server.Logger.Error("CreateShortURL", "error", err)
+		server.logCodesExhausted(err)
+		server.writeError(w, "CreateShortURL", err)
 		return
 	}
 
-	// Encode the ID
-	shortCode := url_converter.EncodeID(id, server.Config.XorSecretKey)
+	// Encode the ID, an alias or random code replaces the generated code in the response
+	shortCode := server.Converter.Encode(id)
+	if link.Alias != "" {
+		shortCode = link.Alias
+	}
 	server.Logger.Debug("CreateShortURL", "Original ID", id, "Long URL", url.Url, "Short Code", shortCode, "address", server.getClientIP(r))
 
 	response := model.ShortUrlResponse{LongUrl: url.Url, ShortUrl: shortCode}
+	if !link.ExpiresAt.IsZero() {
+		response.ExpiresAt = &link.ExpiresAt
+	}
 
 	// store it in cache
 	//server.Cache.Set(shortCode, response.LongUrl)
 
+	// an existing link is answered with 200 so repeated posts can be told apart from new links
+	status := http.StatusCreated // 201
+	if !created {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.Logger.Error("Failed to encode response", "error", err)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+
+}
+
+// maxBatchSize caps the urls of one batch request, a whole batch is held in memory
+const maxBatchSize = 50000
+
+// maxBatchBytes caps the body of a batch request before it is read into memory
+const maxBatchBytes = 32 << 20
+
+// batchItem is one decoded url of a batch request, err is set if it could not be decoded
+type batchItem struct {
+	url model.Url
+	err error
+}
+
+// CreateShortURLBatch creates many short URLs in a single store transaction. The body is either a JSON array
+// of urls or NDJSON with one url per line, and the response uses the same format. Every url gets its own
+// result, an invalid url is reported there and does not fail the rest of the batch.
+func (server *URLShortener) CreateShortURLBatch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	ndjson := !isJSONArray(body)
+
+	var items []batchItem
+	var err error
+	if ndjson {
+		items, err = readNDJSONBatch(body)
+	} else {
+		items, err = readJSONBatch(body)
+	}
+	if err == nil && len(items) == 0 {
+		err = badRequest(codeInvalidBatch, "Batch is empty")
+	}
+	if err != nil {
+		server.writeError(w, "CreateShortURLBatch invalid request", err)
+		return
+	}
+
+	// only the valid urls reach the store, positions maps them back to their results
+	now := time.Now()
+	response := model.BatchResponse{Results: make([]model.BatchResult, len(items))}
+	results := response.Results
+	links := make([]store.Link, 0, len(items))
+	positions := make([]int, 0, len(items))
+	for i, item := range items {
+		results[i] = model.BatchResult{Index: i, LongUrl: item.url.Url}
+
+		var link store.Link
+		err := item.err
+		if err == nil {
+			link, err = server.linkFromRequest(item.url, now)
+		}
+		if err != nil {
+			problem := problemFor(err)
+			results[i].Error, results[i].Code = problem.Detail, problem.Code
+			continue
+		}
+
+		// urls that may reuse a link are looked up one by one, so a url repeated in the batch is
+		// found as well, the others are stored together
+		if !link.Random && server.dedup(item.url) {
+			id, created, err := server.Store.ShortenDedup(link)
+			server.fillBatchResult(&response, i, link, id, created, err)
+			continue
+		}
+
+		links = append(links, link)
+		positions = append(positions, i)
+	}
+
+	// RandomCodes fills in the codes of the random links
+	var stored []store.BatchResult
+	if server.RandomCodes != nil {
+		stored, err = server.RandomCodes.ShortenBatch(server.Store, links)
+	} else {
+		stored, err = server.Store.ShortenBatch(links)
+	}
+	if err != nil {
+		server.writeError(w, "CreateShortURLBatch", err)
+		return
+	}
+
+	for j, result := range stored {
+		server.fillBatchResult(&response, positions[j], links[j], result.ID, true, result.Err)
+	}
+	response.Failed = len(results) - response.Created - response.Existing
+	server.Logger.Info("CreateShortURLBatch", "created", response.Created, "existing", response.Existing, "failed", response.Failed, "address", server.getClientIP(r))
+
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				server.Logger.Error("Failed to encode response", "error", err)
+				return
+			}
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated) // 201
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		server.Logger.Error("Failed to encode response", "error", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+}
+
+// fillBatchResult reports the stored link of the i-th url of a batch, or why it was not stored
+func (server *URLShortener) fillBatchResult(response *model.BatchResponse, i int, link store.Link, id int64, created bool, err error) {
+	result := &response.Results[i]
+	if err != nil {
+		server.logCodesExhausted(err)
+		problem := problemFor(err)
+		result.Error, result.Code = problem.Detail, problem.Code
 		return
 	}
 
+	result.ShortUrl = server.Converter.Encode(id)
+	if link.Alias != "" {
+		result.ShortUrl = link.Alias
+	}
+	if !link.ExpiresAt.IsZero() {
+		result.ExpiresAt = &link.ExpiresAt
+	}
+	if created {
+		response.Created++
+	} else {
+		result.Existing = true
+		response.Existing++
+	}
+}
+
+// isJSONArray peeks past leading whitespace to tell a JSON array body from NDJSON
+func isJSONArray(body *bufio.Reader) bool {
+	for {
+		b, err := body.Peek(1)
+		if err != nil {
+			return false
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			return b[0] == '['
+		}
+		body.ReadByte()
+	}
+}
+
+func readJSONBatch(body io.Reader) ([]batchItem, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, bodyError(err)
+	}
+	if len(raw) > maxBatchSize {
+		return nil, badRequest(codeInvalidBatch, fmt.Sprintf("Batch exceeds %d urls", maxBatchSize))
+	}
+
+	items := make([]batchItem, len(raw))
+	for i, message := range raw {
+		if err := json.Unmarshal(message, &items[i].url); err != nil {
+			items[i].err = badRequest(codeInvalidBody, "Invalid url object")
+		}
+	}
+	return items, nil
+}
+
+// readNDJSONBatch reads one url per line, blank lines are skipped
+func readNDJSONBatch(body io.Reader) ([]batchItem, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var items []batchItem
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxBatchSize {
+			return nil, badRequest(codeInvalidBatch, fmt.Sprintf("Batch exceeds %d urls", maxBatchSize))
+		}
+
+		var item batchItem
+		if err := json.Unmarshal(line, &item.url); err != nil {
+			item.err = badRequest(codeInvalidBody, "Invalid url object")
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, bodyError(err)
+	}
+	return items, nil
+}
+
+// bodyError keeps a body over the size limit apart from a malformed one
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return badRequest(codeInvalidBody, "Invalid request body")
+}
+
+// logCodesExhausted tells the operator that a feistel codec with too few bits ran out of codes
+func (server *URLShortener) logCodesExhausted(err error) {
+	if errors.Is(err, store.ErrCodesExhausted) {
+		server.Logger.Error("No short codes left, the codec needs more bits", "max id", url_converter.MaxID(server.Converter))
+	}
+}
+
+// dedup reports whether a url may reuse an existing link, the request overrides the config
+func (server *URLShortener) dedup(url model.Url) bool {
+	if url.Dedup != nil {
+		return *url.Dedup
+	}
+	return server.Config.Dedup
+}
+
+// linkFromRequest validates a requested url, the error message is meant for the client
+func (server *URLShortener) linkFromRequest(url model.Url, now time.Time) (store.Link, error) {
+	if !hasProtocol(url.Url) {
+		return store.Link{}, badRequest(codeMissingProtocol, "Protocol Missing")
+	}
+
+	expiresAt, err := expiryFromRequest(url, now)
+	if err != nil {
+		return store.Link{}, badRequest(codeInvalidExpiry, err.Error())
+	}
+
+	if url.Alias != "" {
+		if err := server.validateAlias(url.Alias); err != nil {
+			return store.Link{}, badRequest(codeInvalidAlias, err.Error())
+		}
+	}
+
+	// a requested alias takes the place of the random code
+	random := server.Config.RandomCodes
+	if url.Random != nil {
+		random = *url.Random
+	}
+	random = random && url.Alias == ""
+	if random && server.RandomCodes == nil {
+		return store.Link{}, badRequest(codeInvalidBody, "Random codes are not available")
+	}
+
+	link := store.Link{LongUrl: url.Url, ExpiresAt: expiresAt, Alias: url.Alias, Random: random}
+
+	// the store refuses a link whose ID the converter cannot encode, a random code needs no ID code
+	if maxID := url_converter.MaxID(server.Converter); !random && maxID < math.MaxInt64 {
+		link.MaxID = maxID
+	}
+	return link, nil
+}
+
+func (server *URLShortener) recordClick(r *http.Request, shortCode string, id int64) {
+	if server.Clicks == nil {
+		return
+	}
+
+	server.Clicks.Record(store.Click{
+		UrlID:     id,
+		Code:      shortCode,
+		ClickedAt: time.Now(),
+		IP:        server.getClientIP(r),
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+	})
+}
+
+// lookupLink resolves a short code the same way for every handler, aliases first and then generated codes
+func (server *URLShortener) lookupLink(shortCode string) (store.Link, error) {
+	link, _, err := server.resolveLink(shortCode)
+	return link, err
+}
+
+// resolveLink is lookupLink that also reports whether the code is an alias
+func (server *URLShortener) resolveLink(shortCode string) (store.Link, bool, error) {
+	link, err := server.Store.LookupAlias(shortCode)
+	if errors.Is(err, store.ErrNotFound) && server.RandomCodes != nil {
+		// a random code in another spelling of the alphabet, aliases keep their case
+		if normalized := server.RandomCodes.Normalize(shortCode); normalized != shortCode {
+			link, err = server.Store.LookupAlias(normalized)
+			if err == nil && !link.Random {
+				link, err = store.Link{}, store.ErrNotFound
+			}
+		}
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return link, err == nil, err
+	}
+
+	// an alias-shaped code that is not an alias
+	decodedID, err := server.Converter.Decode(shortCode)
+	if err != nil {
+		return store.Link{}, false, store.ErrNotFound
+	}
+	server.Logger.Info("lookupLink", "Decoded ID", decodedID)
+
+	// links with a random code must not be reachable by enumerating IDs
+	link, err = server.Store.Lookup(decodedID)
+	if err == nil && link.Random {
+		return store.Link{}, false, store.ErrNotFound
+	}
+	return link, false, err
+}
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateShortCode rejects a code that can be neither a generated code nor an alias,
+// before it reaches the cache or the store
+func (server *URLShortener) validateShortCode(shortCode string) error {
+	if shortCode == "" {
+		return badRequest(codeMissingShortUrl, "Missing or invalid URL")
+	}
+
+	_, err := server.Converter.Decode(shortCode)
+	// aliases never fail the check character, so this is a mistyped code that cannot exist
+	if errors.Is(err, url_converter.ErrCheckCharMismatch) {
+		return &mistypedError{code: shortCode, suggestions: server.suggest(shortCode)}
+	}
+	if err != nil && !aliasPattern.MatchString(shortCode) {
+		return badRequest(codeInvalidShortCode, "Invalid short code: "+err.Error())
+	}
+	return nil
+}
+
+// suggest returns the codes a mistyped code was probably meant to be, if the converter knows them.
+// They are not looked up, a mistyped code must stay cheaper to answer than a lookup, so some may
+// have no link.
+func (server *URLShortener) suggest(shortCode string) []string {
+	if checked, ok := server.Converter.(*url_converter.Checked); ok {
+		return checked.Suggest(shortCode)
+	}
+	return nil
+}
+
+func (server *URLShortener) validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errors.New("Alias must be 1-64 characters of letters, digits, '-' or '_'")
+	}
+
+	// an alias that decodes to an ID would shadow the generated code of that ID, and one that
+	// fails the check character would be taken for a mistyped code
+	_, err := server.Converter.Decode(alias)
+	if err == nil || errors.Is(err, url_converter.ErrCheckCharMismatch) {
+		return errors.New("Alias collides with generated short codes, include a '-' or '_'")
+	}
+
+	return nil
 }
 
 func (server *URLShortener) getClientIP(r *http.Request) string {
@@ -135,3 +535,412 @@ func (server *URLShortener) getClientIP(r *http.Request) string {
 	server.Logger.Debug("getClientIP fallback to RemoteAddr")
 	return r.RemoteAddr
 }
+
+func (server *URLShortener) DeleteShortURL(w http.ResponseWriter, r *http.Request) {
+	shortUrl := r.PathValue("url")
+	server.Logger.Debug("DeleteShortURL", "url", shortUrl, "address", server.getClientIP(r))
+
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "DeleteShortURL", err)
+		return
+	}
+
+	link, err := server.lookupLink(shortUrl)
+	if err == nil {
+		err = server.Store.Delete(link.ID)
+	}
+	if err != nil {
+		server.writeError(w, "DeleteShortURL", err)
+		return
+	}
+
+	server.invalidateCache(link)
+	server.Logger.Info("DeleteShortURL - deleted", "shortUrl", shortUrl, "id", link.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *URLShortener) DisableShortURL(w http.ResponseWriter, r *http.Request) {
+	server.setDisabled(w, r, true)
+}
+
+func (server *URLShortener) EnableShortURL(w http.ResponseWriter, r *http.Request) {
+	server.setDisabled(w, r, false)
+}
+
+func (server *URLShortener) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	shortUrl := r.PathValue("url")
+	server.Logger.Debug("setDisabled", "url", shortUrl, "disabled", disabled, "address", server.getClientIP(r))
+
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "setDisabled", err)
+		return
+	}
+
+	link, err := server.lookupLink(shortUrl)
+	if err == nil {
+		err = server.Store.SetDisabled(link.ID, disabled)
+	}
+	if err != nil {
+		server.writeError(w, "setDisabled", err)
+		return
+	}
+
+	server.invalidateCache(link)
+	server.Logger.Info("setDisabled - updated", "shortUrl", shortUrl, "id", link.ID, "disabled", disabled)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *URLShortener) UpdateShortURL(w http.ResponseWriter, r *http.Request) {
+	shortUrl := r.PathValue("url")
+	server.Logger.Debug("UpdateShortURL", "url", shortUrl, "address", server.getClientIP(r))
+
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "UpdateShortURL", err)
+		return
+	}
+
+	var update model.UrlUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		server.Logger.Error("Failed to decode JSON", "error", err, "address", server.getClientIP(r))
+		writeProblem(w, newProblem(http.StatusBadRequest, codeInvalidBody, "Invalid request body"))
+		return
+	}
+	defer r.Body.Close()
+
+	if !hasProtocol(update.Url) {
+		server.Logger.Error("Missing Protocol", "address", server.getClientIP(r))
+		writeProblem(w, newProblem(http.StatusBadRequest, codeMissingProtocol, "Protocol Missing"))
+		return
+	}
+
+	if update.Actor == "" {
+		update.Actor = server.getClientIP(r)
+	}
+
+	link, err := server.lookupLink(shortUrl)
+	if err == nil {
+		err = server.Store.Update(link.ID, update.Url, update.Actor)
+	}
+	if err != nil {
+		server.writeError(w, "UpdateShortURL", err)
+		return
+	}
+
+	server.Logger.Info("UpdateShortURL - updated", "shortUrl", shortUrl, "id", link.ID, "previous", link.LongUrl, "longUrl", update.Url, "actor", update.Actor)
+	link.LongUrl = update.Url
+	server.refreshCache(link)
+
+	response := model.ShortUrlResponse{LongUrl: link.LongUrl, ShortUrl: shortUrl}
+	if !link.ExpiresAt.IsZero() {
+		response.ExpiresAt = &link.ExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.Logger.Error("Failed to encode response", "error", err)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+}
+
+func (server *URLShortener) GetHistory(w http.ResponseWriter, r *http.Request) {
+	shortUrl := r.PathValue("url")
+	server.Logger.Debug("GetHistory", "url", shortUrl, "address", server.getClientIP(r))
+
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "GetHistory", err)
+		return
+	}
+
+	var history []store.Revision
+	link, err := server.lookupLink(shortUrl)
+	if err == nil {
+		history, err = server.Store.History(link.ID)
+	}
+	if err != nil {
+		server.writeError(w, "GetHistory", err)
+		return
+	}
+
+	response := model.HistoryResponse{ShortUrl: shortUrl, LongUrl: link.LongUrl, History: make([]model.Revision, 0, len(history))}
+	for _, revision := range history {
+		response.History = append(response.History, model.Revision{LongUrl: revision.LongUrl, ChangedAt: revision.ChangedAt, Actor: revision.Actor})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.Logger.Error("Failed to encode response", "error", err)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+}
+
+// GetStats reports the clicks of a link, the optional hours, days and top query parameters
+// control the length of the hourly and daily series and of the top lists
+func (server *URLShortener) GetStats(w http.ResponseWriter, r *http.Request) {
+	shortUrl := r.PathValue("url")
+	server.Logger.Debug("GetStats", "url", shortUrl, "address", server.getClientIP(r))
+
+	if err := server.validateShortCode(shortUrl); err != nil {
+		server.writeError(w, "GetStats", err)
+		return
+	}
+
+	hours, err := queryInt(r, "hours", 24, 24*31)
+	if err != nil {
+		server.writeError(w, "GetStats", badRequest(codeInvalidQuery, err.Error()))
+		return
+	}
+	days, err := queryInt(r, "days", 30, 366)
+	if err != nil {
+		server.writeError(w, "GetStats", badRequest(codeInvalidQuery, err.Error()))
+		return
+	}
+	top, err := queryInt(r, "top", 10, 100)
+	if err != nil {
+		server.writeError(w, "GetStats", badRequest(codeInvalidQuery, err.Error()))
+		return
+	}
+
+	now := time.Now()
+	query := store.StatsQuery{
+		HourlySince: now.Add(-time.Duration(hours-1) * time.Hour),
+		DailySince:  now.AddDate(0, 0, -(days - 1)),
+		Top:         top,
+	}
+
+	var stats store.Stats
+	link, err := server.lookupLink(shortUrl)
+	if err == nil {
+		stats, err = server.Store.Stats(link.ID, query)
+	}
+	if err != nil {
+		server.writeError(w, "GetStats", err)
+		return
+	}
+
+	response := model.StatsResponse{
+		ShortUrl:       shortUrl,
+		LongUrl:        link.LongUrl,
+		TotalClicks:    stats.TotalClicks,
+		UniqueVisitors: stats.UniqueVisitors,
+		Hourly:         toStatsBuckets(stats.Hourly),
+		Daily:          toStatsBuckets(stats.Daily),
+		TopReferrers:   toStatsCounts(stats.TopReferrers),
+		TopUserAgents:  toStatsCounts(stats.TopUserAgents),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.Logger.Error("Failed to encode response", "error", err)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+}
+
+// GetRandomCodeMetrics reports the random codes generated since the start, all zero if random codes
+// are not available
+func (server *URLShortener) GetRandomCodeMetrics(w http.ResponseWriter, r *http.Request) {
+	server.Logger.Debug("GetRandomCodeMetrics", "address", server.getClientIP(r))
+
+	var response model.RandomCodeMetricsResponse
+	if server.RandomCodes != nil {
+		metrics := server.RandomCodes.Metrics()
+		response = model.RandomCodeMetricsResponse{
+			Generated:     metrics.Generated,
+			Collisions:    metrics.Collisions,
+			CollisionRate: metrics.CollisionRate(),
+			Rejected:      metrics.Rejected,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.Logger.Error("Failed to encode response", "error", err)
+		writeProblem(w, newProblem(http.StatusInternalServerError, codeInternal, "Failed to encode response"))
+		return
+	}
+}
+
+func toStatsBuckets(buckets []store.Bucket) []model.StatsBucket {
+	result := make([]model.StatsBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, model.StatsBucket{Start: bucket.Start, Clicks: bucket.Clicks})
+	}
+	return result
+}
+
+func toStatsCounts(counts []store.Count) []model.StatsCount {
+	result := make([]model.StatsCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, model.StatsCount{Value: count.Value, Clicks: count.Clicks})
+	}
+	return result
+}
+
+// queryInt reads an optional positive integer query parameter capped at max
+func queryInt(r *http.Request, name string, fallback int, max int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, max)
+	}
+	return n, nil
+}
+
+// refreshCache replaces the cached destination under every code of a link
+func (server *URLShortener) refreshCache(link store.Link) {
+	if link.Disabled || link.Expired(time.Now()) {
+		server.invalidateCache(link)
+		return
+	}
+
+	// only the codes that are cached and hold this link, an entry is made by a redirect
+	for _, code := range server.cacheCodes(link) {
+		if value, err := server.Cache.Peek(code); err == nil && decodeCacheValue(value).ID == link.ID {
+			server.Cache.SetWithTTL(code, encodeCacheValue(link), server.cacheTTL(link))
+		}
+	}
+}
+
+// cacheTTL keeps a link cached no longer than the configured TTL and not past its own expiry
+func (server *URLShortener) cacheTTL(link store.Link) time.Duration {
+	ttl := time.Duration(server.Config.CacheTTLSeconds) * time.Second
+	if link.ExpiresAt.IsZero() {
+		return ttl
+	}
+
+	untilExpiry := time.Until(link.ExpiresAt)
+	if untilExpiry <= 0 {
+		// already checked by the caller, the smallest TTL still lets the cache drop it
+		return time.Nanosecond
+	}
+	if ttl == 0 || untilExpiry < ttl {
+		return untilExpiry
+	}
+	return ttl
+}
+
+// invalidateCache drops every code a link can be cached under
+func (server *URLShortener) invalidateCache(link store.Link) {
+	for _, code := range server.cacheCodes(link) {
+		server.Cache.Delete(code)
+	}
+}
+
+// aliasCacheKey is the key aliases and random codes are cached under
+func aliasCacheKey(alias string) string {
+	return "a:" + alias
+}
+
+// cacheKey is the key a code is cached under. Every spelling of a generated code, like a lowercase
+// one, shares the entry of the code of the key it was made with. The codes of other key versions
+// keep their own entries, one of them may be an alias made before the rotation.
+func (server *URLShortener) cacheKey(shortCode string) string {
+	if code, err := url_converter.Canonical(server.Converter, shortCode); err == nil {
+		return code
+	}
+	return aliasCacheKey(shortCode)
+}
+
+// cacheLink caches the link a code resolved to under the key of the code. The store finds aliases
+// before generated codes, so the link is only cached if every spelling of the key gets it too:
+// aliases and random codes in their exact spelling, and generated codes whose key is no alias. An
+// alias that also decodes, made before a key rotation, is never cached.
+func (server *URLShortener) cacheLink(shortCode string, key string, link store.Link, byAlias bool) {
+	if byAlias {
+		if key != aliasCacheKey(link.Alias) {
+			return
+		}
+	} else if key != shortCode {
+		// the store has only looked up the spelling as an alias
+		if _, err := server.Store.LookupAlias(key); !errors.Is(err, store.ErrNotFound) {
+			return
+		}
+	}
+	server.Cache.SetWithTTL(key, encodeCacheValue(link), server.cacheTTL(link))
+}
+
+// cacheCodes are the keys a link can be cached under, with key rotation a link has a code for
+// every key version
+func (server *URLShortener) cacheCodes(link store.Link) []string {
+	var codes []string
+	if !link.Random {
+		codes = url_converter.AllCodes(server.Converter, link.ID)
+	}
+	if link.Alias != "" {
+		codes = append(codes, server.cacheKey(link.Alias))
+	}
+	return codes
+}
+
+func hasProtocol(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// maxTTLSeconds caps ttl_seconds at 100 years, far below where the lifetime would overflow a time.Duration
+const maxTTLSeconds = 100 * 365 * 24 * 60 * 60
+
+// expiryFromRequest resolves the optional expires_at/ttl_seconds fields into an absolute expiry,
+// a zero time means the link never expires
+func expiryFromRequest(url model.Url, now time.Time) (time.Time, error) {
+	if url.ExpiresAt != nil && url.TTLSeconds != 0 {
+		return time.Time{}, errors.New("Only one of expires_at and ttl_seconds can be set")
+	}
+
+	if url.TTLSeconds < 0 {
+		return time.Time{}, errors.New("ttl_seconds must be positive")
+	}
+	if url.TTLSeconds > maxTTLSeconds {
+		return time.Time{}, fmt.Errorf("ttl_seconds must be at most %d", maxTTLSeconds)
+	}
+
+	if url.TTLSeconds > 0 {
+		return now.Add(time.Duration(url.TTLSeconds) * time.Second).Truncate(time.Second).UTC(), nil
+	}
+
+	if url.ExpiresAt != nil {
+		if !url.ExpiresAt.After(now) {
+			return time.Time{}, errors.New("expires_at must be in the future")
+		}
+		return url.ExpiresAt.Truncate(time.Second).UTC(), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// The cache only holds strings, so the link ID and expiry travel with the long url as
+// "<id> <unix seconds> <long url>", where 0 means the link never expires
+func encodeCacheValue(link store.Link) string {
+	var unix int64
+	if !link.ExpiresAt.IsZero() {
+		unix = link.ExpiresAt.Unix()
+	}
+	return strconv.FormatInt(link.ID, 10) + " " + strconv.FormatInt(unix, 10) + " " + link.LongUrl
+}
+
+func decodeCacheValue(value string) store.Link {
+	fields := strings.SplitN(value, " ", 3)
+	if len(fields) != 3 {
+		return store.Link{LongUrl: value}
+	}
+	id, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return store.Link{LongUrl: value}
+	}
+	unix, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return store.Link{LongUrl: value}
+	}
+	link := store.Link{ID: id, LongUrl: fields[2]}
+	if unix != 0 {
+		link.ExpiresAt = time.Unix(unix, 0).UTC()
+	}
+	return link
+}
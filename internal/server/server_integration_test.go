@@ -56,7 +56,6 @@ func TestAPI(t *testing.T) {
 	}()
 	store.SetStoreOptions()
 
-	url_converter.InitBase62Array(shuffleKey)
 	// logging
 	slogger, cleanup := logger.SetupLogger(config.LogFilename, config.LogLevel, config.Production)
 	defer func() {
@@ -65,9 +64,17 @@ func TestAPI(t *testing.T) {
 	}()
 
 	// cache
-	cache := cache.NewCache(config.CacheCapacity)
+	cache, err := cache.NewCache(cache.Options{
+		Capacity: config.CacheCapacity,
+		TTL:      time.Duration(config.CacheTTLSeconds) * time.Second,
+		Policy:   config.CachePolicy,
+		Shards:   config.CacheShards,
+	})
+	if err != nil {
+		log.Fatalf("Error creating cache: %v", err)
+	}
 
-	server := NewServer(store, http.NewServeMux(), config, slogger, cache)
+	server := NewServer(store, http.NewServeMux(), config, slogger, cache, url_converter.NewCodec(shuffleKey, config.XorSecretKey))
 	//server := NewServer(store, http.NewServeMux(), config, slogger)
 	server.SetupHandlers()
 
@@ -3,15 +3,20 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/voukatas/url-shortener/internal/model"
+	"github.com/voukatas/url-shortener/internal/store"
 	"github.com/voukatas/url-shortener/internal/url_converter"
+	"github.com/voukatas/url-shortener/pkg/cache"
 )
 
 var (
@@ -21,17 +26,73 @@ var (
 
 // mock db
 type mockStore struct {
+	expiresAt time.Time
+	alias     string
+	disabled  bool
+	deleted   bool
+	history   []store.Revision
+	lookups   int
 }
 
-func (store *mockStore) Shorten(string) (int64, error) {
+func (s *mockStore) Shorten(link store.Link) (int64, error) {
+	if link.Alias != "" && link.Alias == s.alias {
+		return 0, store.ErrAliasTaken
+	}
 	return id, nil
 }
-func (store *mockStore) Lookup(int64) (string, error) {
-	return expectedGetUrl, nil
+func (s *mockStore) ShortenDedup(link store.Link) (int64, bool, error) {
+	id, err := s.Shorten(link)
+	return id, err == nil, err
+}
+func (s *mockStore) ShortenBatch(links []store.Link) ([]store.BatchResult, error) {
+	results := make([]store.BatchResult, len(links))
+	for i, link := range links {
+		results[i].ID, results[i].Err = s.Shorten(link)
+	}
+	return results, nil
+}
+func (s *mockStore) Lookup(int64) (store.Link, error) {
+	s.lookups++
+	return store.Link{ID: id, LongUrl: expectedGetUrl, ExpiresAt: s.expiresAt, Alias: s.alias, Disabled: s.disabled}, nil
+}
+func (s *mockStore) LookupAlias(alias string) (store.Link, error) {
+	s.lookups++
+	if alias == "" || alias != s.alias {
+		return store.Link{}, store.ErrNotFound
+	}
+	return store.Link{ID: id, LongUrl: expectedGetUrl, ExpiresAt: s.expiresAt, Alias: alias, Disabled: s.disabled}, nil
+}
+func (s *mockStore) Delete(int64) error {
+	s.deleted = true
+	return nil
+}
+func (s *mockStore) SetDisabled(_ int64, disabled bool) error {
+	s.disabled = disabled
+	return nil
 }
-func (store *mockStore) Close() {
+func (s *mockStore) Update(_ int64, longUrl string, actor string) error {
+	s.history = append([]store.Revision{{LongUrl: expectedGetUrl, ChangedAt: time.Now(), Actor: actor}}, s.history...)
+	return nil
 }
-func (store *mockStore) SetStoreOptions() {
+func (s *mockStore) History(int64) ([]store.Revision, error) {
+	return s.history, nil
+}
+func (s *mockStore) RecordClicks([]store.Click) error {
+	return nil
+}
+func (s *mockStore) Stats(int64, store.StatsQuery) (store.Stats, error) {
+	return store.Stats{
+		TotalClicks:    3,
+		UniqueVisitors: 2,
+		Hourly:         []store.Bucket{{Start: time.Now().Truncate(time.Hour), Clicks: 3}},
+		Daily:          []store.Bucket{{Start: time.Now().Truncate(24 * time.Hour), Clicks: 3}},
+		TopReferrers:   []store.Count{{Value: "https://news.example.com", Clicks: 2}},
+		TopUserAgents:  []store.Count{{Value: "curl", Clicks: 3}},
+	}, nil
+}
+func (s *mockStore) Close() {
+}
+func (s *mockStore) SetStoreOptions() {
 }
 
 // mock logger
@@ -57,12 +118,37 @@ func (lru *mockCache) Get(key string) (string, error) {
 	lru.getFuncCalled = true
 	return "", errors.New("Key not found")
 }
+func (lru *mockCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	lru.setFuncCalled = true
+}
+func (lru *mockCache) Peek(key string) (string, error) {
+	return "", errors.New("Key not found")
+}
+func (lru *mockCache) Delete(key string) {
+}
+func (lru *mockCache) Len() int {
+	return 0
+}
+func (lru *mockCache) Purge() {
+}
+func (lru *mockCache) Stop() {
+}
+
+// mock click recorder
+type mockClicks struct {
+	clicks []store.Click
+}
+
+func (m *mockClicks) Record(click store.Click) {
+	m.clicks = append(m.clicks, click)
+}
 
 var shuffleKey = "your_key"
 
+var testCodec = url_converter.NewCodec(shuffleKey, 15489079)
+
 func TestRedirectURLSuccess(t *testing.T) {
-	url_converter.InitBase62Array(shuffleKey)
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{})
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
 
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -80,9 +166,8 @@ func TestRedirectURLSuccess(t *testing.T) {
 }
 
 func TestRedirectURLSuccessWithCacheUse(t *testing.T) {
-	url_converter.InitBase62Array(shuffleKey)
 	mCache := &mockCache{}
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, mCache)
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, mCache, testCodec)
 
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -105,9 +190,8 @@ func TestRedirectURLSuccessWithCacheUse(t *testing.T) {
 
 func TestRedirectURLStatusBadRequest(t *testing.T) {
 
-	url_converter.InitBase62Array(shuffleKey)
 	//server := NewServer(&mockStore{}, http.NewServeMux())
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{})
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
 
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -122,10 +206,163 @@ func TestRedirectURLStatusBadRequest(t *testing.T) {
 	}
 
 }
+func TestRedirectURLInvalidShortCode(t *testing.T) {
+
+	for _, shortUrl := range []string{"ab$c", "neBlT!", strings.Repeat("a", 65)} {
+		mock := &mockCache{}
+		server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, mock, testCodec)
+
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", shortUrl)
+		resp := httptest.NewRecorder()
+
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected %v received %v", shortUrl, http.StatusBadRequest, resp.Code)
+		}
+		if mock.getFuncCalled {
+			t.Errorf("%q: expected the cache not to be used", shortUrl)
+		}
+	}
+}
+
+func TestRedirectURLMistypedShortCode(t *testing.T) {
+	mStore := &mockStore{}
+	mCache := &mockCache{}
+	codec := url_converter.NewChecked(url_converter.Base62, testCodec)
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, mCache, codec)
+
+	shortCode := codec.Encode(id)
+	typo := []byte(shortCode)
+	typo[0] = shortCode[1]
+	if typo[0] == shortCode[0] {
+		typo[0] = shortCode[2]
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", string(typo))
+	resp := httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("expected %v received %v", http.StatusNotFound, resp.Code)
+	}
+	if mStore.lookups != 0 || mCache.getFuncCalled {
+		t.Errorf("expected a mistyped code to be rejected without a lookup")
+	}
+
+	var problem model.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if problem.Code != codeMistyped || !slices.Contains(problem.Suggestions, shortCode) {
+		t.Errorf("expected %v with the suggestion %v, received %+v", codeMistyped, shortCode, problem)
+	}
+
+	// the correct code still redirects
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", shortCode)
+	resp = httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusFound {
+		t.Errorf("expected %v received %v", http.StatusFound, resp.Code)
+	}
+}
+
+func TestRedirectURLNormalizesShortCode(t *testing.T) {
+	codec, err := url_converter.NewPaddedCodec(url_converter.Crockford32, shuffleKey, 15489079, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mStore := &mockStore{}
+	lru := cache.NewLRUCache(10)
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, lru, codec)
+
+	// a code read out over the phone and typed in lowercase with o for 0
+	shortCode := codec.Encode(id)
+	typed := strings.ReplaceAll(strings.ToLower(shortCode), "0", "o")
+	lookups := 0
+	for round := 0; round < 2; round++ {
+		lookups = mStore.lookups
+		for _, shortUrl := range []string{typed, shortCode} {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.SetPathValue("url", shortUrl)
+			resp := httptest.NewRecorder()
+			server.RedirectURL(resp, req)
+			if resp.Code != http.StatusFound {
+				t.Errorf("%v: expected %v received %v", shortUrl, http.StatusFound, resp.Code)
+			}
+		}
+	}
+
+	// both spellings share the cache entry of the code
+	if mStore.lookups != lookups {
+		t.Errorf("expected both spellings to be served from the cache")
+	}
+	if _, err := lru.Get(shortCode); err != nil || lru.Len() != 1 {
+		t.Errorf("expected the link to be cached only under %v", shortCode)
+	}
+}
+
+func TestRedirectURLAliasAfterKeyRotation(t *testing.T) {
+	mStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mStore.Close()
+
+	first, _ := url_converter.NewPaddedCodec(url_converter.Base62, "first-key", 87654321, url_converter.Base62.MaxLength())
+	second, _ := url_converter.NewPaddedCodec(url_converter.Base62, "second-key", 11223344, url_converter.Base62.MaxLength())
+	keyring, err := url_converter.NewKeyring(url_converter.Base62, 2, map[int]url_converter.Converter{1: first, 2: second}, testCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the alias was too long for a code before the rotation, now it is a code of the other link
+	otherID, err := mStore.Shorten(store.Link{LongUrl: "http://other.example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := "1" + first.Encode(otherID)
+	if _, err := mStore.Shorten(store.Link{LongUrl: expectedGetUrl, Alias: alias}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, cache.NewLRUCache(10), keyring)
+	for _, test := range []struct{ code, location string }{
+		{alias, expectedGetUrl},
+		{keyring.Encode(otherID), "http://other.example.com/"},
+		{alias, expectedGetUrl},
+		{testCodec.Encode(otherID), "http://other.example.com/"},
+		{alias, expectedGetUrl},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", test.code)
+		resp := httptest.NewRecorder()
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound || resp.Header().Get("Location") != test.location {
+			t.Errorf("%v: expected %v to %v received %v to %v", test.code, http.StatusFound, test.location, resp.Code, resp.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCreateShortURLAliasWithCheckChar(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{}, &mockLogger{}, &mockCache{}, url_converter.NewChecked(url_converter.Base62, testCodec))
+
+	// an alphanumeric alias would be taken for a mistyped code
+	for alias, status := range map[string]int{"springsale": http.StatusBadRequest, "spring-sale": http.StatusCreated} {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "http://example.com", "alias": "`+alias+`"}`))
+		resp := httptest.NewRecorder()
+		server.CreateShortURL(resp, req)
+		if resp.Code != status {
+			t.Errorf("%v: expected %v received %v", alias, status, resp.Code)
+		}
+	}
+}
+
 func TestCreateShortURLSuccess(t *testing.T) {
-	url_converter.InitBase62Array(shuffleKey)
 	//server := NewServer(&mockStore{}, http.NewServeMux())
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{})
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
 
 	body := []byte(`{"url": "http://example.com"}`)
 	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
@@ -160,8 +397,7 @@ func TestCreateShortURLSuccess(t *testing.T) {
 }
 
 func TestCreateShortURLBadRequest(t *testing.T) {
-	url_converter.InitBase62Array(shuffleKey)
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{})
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
 
 	body := []byte("invalid_json")
 	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
@@ -177,8 +413,7 @@ func TestCreateShortURLBadRequest(t *testing.T) {
 }
 
 func TestCreateShortURLMissingProtocol(t *testing.T) {
-	url_converter.InitBase62Array(shuffleKey)
-	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{})
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
 
 	body := []byte(`{"url": "example.com"}`)
 	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
@@ -192,3 +427,931 @@ func TestCreateShortURLMissingProtocol(t *testing.T) {
 		t.Errorf("expected: %v received: %v", http.StatusBadRequest, resp.Code)
 	}
 }
+
+func TestCreateShortURLWithTTL(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	body := []byte(`{"url": "http://example.com", "ttl_seconds": 3600}`)
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	server.CreateShortURL(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected: %v received: %v", http.StatusCreated, resp.Code)
+	}
+
+	var actual model.ShortUrlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&actual); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+
+	if actual.ExpiresAt == nil || actual.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("expected expiry about an hour from now, received: %v", actual.ExpiresAt)
+	}
+}
+
+func TestCreateShortURLInvalidExpiry(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	bodies := []string{
+		`{"url": "http://example.com", "ttl_seconds": -1}`,
+		`{"url": "http://example.com", "ttl_seconds": 10000000000}`,
+		`{"url": "http://example.com", "expires_at": "2000-01-01T00:00:00Z"}`,
+		`{"url": "http://example.com", "ttl_seconds": 60, "expires_at": "2999-01-01T00:00:00Z"}`,
+	}
+
+	for _, body := range bodies {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURL(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected: %v received: %v", body, http.StatusBadRequest, resp.Code)
+		}
+	}
+}
+
+func TestRedirectURLExpired(t *testing.T) {
+	server := NewServer(&mockStore{expiresAt: time.Now().Add(-time.Minute)}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "12zPr")
+	resp := httptest.NewRecorder()
+
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusGone {
+		t.Errorf("expected %v received %v", http.StatusGone, resp.Code)
+	}
+	if resp.Header().Get("Location") != "" {
+		t.Errorf("expected no redirect received %v", resp.Header().Get("Location"))
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{CacheTTLSeconds: 60}, &mockLogger{}, &mockCache{}, testCodec)
+
+	if ttl := server.cacheTTL(store.Link{}); ttl != time.Minute {
+		t.Errorf("expected %v received %v", time.Minute, ttl)
+	}
+	if ttl := server.cacheTTL(store.Link{ExpiresAt: time.Now().Add(time.Hour)}); ttl != time.Minute {
+		t.Errorf("expected %v received %v", time.Minute, ttl)
+	}
+	// a link that expires before the TTL is only cached until its expiry
+	if ttl := server.cacheTTL(store.Link{ExpiresAt: time.Now().Add(10 * time.Second)}); ttl > 10*time.Second || ttl <= 0 {
+		t.Errorf("expected at most 10s received %v", ttl)
+	}
+
+	server.Config.CacheTTLSeconds = 0
+	if ttl := server.cacheTTL(store.Link{}); ttl != 0 {
+		t.Errorf("expected no TTL received %v", ttl)
+	}
+	if ttl := server.cacheTTL(store.Link{ExpiresAt: time.Now().Add(time.Hour)}); ttl > time.Hour || ttl <= 0 {
+		t.Errorf("expected at most 1h received %v", ttl)
+	}
+}
+
+func TestRedirectURLExpiredFromCache(t *testing.T) {
+	lru := cache.NewLRUCache(2)
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, lru, testCodec)
+
+	lru.Set("12zPr", encodeCacheValue(store.Link{LongUrl: expectedGetUrl, ExpiresAt: time.Now().Add(-time.Second)}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "12zPr")
+	resp := httptest.NewRecorder()
+
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusGone {
+		t.Errorf("expected %v received %v", http.StatusGone, resp.Code)
+	}
+}
+
+func TestCreateShortURLWithAlias(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	body := []byte(`{"url": "http://example.com", "alias": "spring-sale"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	resp := httptest.NewRecorder()
+
+	server.CreateShortURL(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected: %v received: %v", http.StatusCreated, resp.Code)
+	}
+
+	var actual model.ShortUrlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&actual); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+
+	if actual.ShortUrl != "spring-sale" {
+		t.Errorf("expected: %v received: %v", "spring-sale", actual.ShortUrl)
+	}
+}
+
+func TestCreateShortURLInvalidAlias(t *testing.T) {
+	server := NewServer(&mockStore{alias: "taken-alias"}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	tests := map[string]int{
+		"neBlT":        http.StatusBadRequest, // decodable, would shadow a generated code
+		"spring sale!": http.StatusBadRequest,
+		"taken-alias":  http.StatusConflict,
+	}
+
+	for alias, status := range tests {
+		body := `{"url": "http://example.com", "alias": "` + alias + `"}`
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURL(resp, req)
+
+		if resp.Code != status {
+			t.Errorf("%s: expected: %v received: %v", alias, status, resp.Code)
+		}
+	}
+}
+
+func TestRedirectURLAlias(t *testing.T) {
+	server := NewServer(&mockStore{alias: "spring-sale"}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "spring-sale")
+	resp := httptest.NewRecorder()
+
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusFound {
+		t.Errorf("expected %v received %v", http.StatusFound, resp.Code)
+	}
+	if resp.Header().Get("Location") != expectedGetUrl {
+		t.Errorf("expected %v received %v", expectedGetUrl, resp.Header().Get("Location"))
+	}
+}
+
+func TestDeleteShortURLInvalidatesCache(t *testing.T) {
+	lru := cache.NewLRUCache(2)
+	mStore := &mockStore{alias: "spring-sale"}
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, lru, testCodec)
+
+	lru.Set("neBlT", encodeCacheValue(store.Link{LongUrl: expectedGetUrl}))
+	lru.Set(aliasCacheKey("spring-sale"), encodeCacheValue(store.Link{LongUrl: expectedGetUrl}))
+
+	req, _ := http.NewRequest(http.MethodDelete, "/", nil)
+	req.SetPathValue("url", "spring-sale")
+	resp := httptest.NewRecorder()
+
+	server.DeleteShortURL(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Errorf("expected %v received %v", http.StatusNoContent, resp.Code)
+	}
+	if !mStore.deleted {
+		t.Error("expected link to be deleted from the store")
+	}
+	for _, code := range []string{"neBlT", aliasCacheKey("spring-sale")} {
+		if _, err := lru.Get(code); err == nil {
+			t.Errorf("expected %v to be removed from the cache", code)
+		}
+	}
+}
+
+func TestDisableShortURL(t *testing.T) {
+	lru := cache.NewLRUCache(2)
+	mStore := &mockStore{}
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, lru, testCodec)
+
+	// warm the cache
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "neBlT")
+	resp := httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusFound {
+		t.Fatalf("expected %v received %v", http.StatusFound, resp.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/", nil)
+	req.SetPathValue("url", "neBlT")
+	resp = httptest.NewRecorder()
+	server.DisableShortURL(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected %v received %v", http.StatusNoContent, resp.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "neBlT")
+	resp = httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusGone {
+		t.Errorf("expected %v received %v", http.StatusGone, resp.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/", nil)
+	req.SetPathValue("url", "neBlT")
+	resp = httptest.NewRecorder()
+	server.EnableShortURL(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected %v received %v", http.StatusNoContent, resp.Code)
+	}
+	if mStore.disabled {
+		t.Error("expected link to be enabled")
+	}
+}
+
+func TestUpdateShortURLRefreshesCache(t *testing.T) {
+	lru := cache.NewLRUCache(2)
+	mStore := &mockStore{}
+	server := NewServer(mStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, lru, testCodec)
+
+	lru.Set("neBlT", encodeCacheValue(store.Link{ID: id, LongUrl: expectedGetUrl}))
+
+	body := `{"url": "http://example.org", "actor": "alice"}`
+	req, _ := http.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	req.SetPathValue("url", "neBlT")
+	resp := httptest.NewRecorder()
+
+	server.UpdateShortURL(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected %v received %v", http.StatusOK, resp.Code)
+	}
+
+	value, err := lru.Get("neBlT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := decodeCacheValue(value); link.LongUrl != "http://example.org" {
+		t.Errorf("expected %v received %v", "http://example.org", link.LongUrl)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", "neBlT")
+	resp = httptest.NewRecorder()
+
+	server.GetHistory(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected %v received %v", http.StatusOK, resp.Code)
+	}
+
+	var history model.HistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if len(history.History) != 1 || history.History[0].LongUrl != expectedGetUrl || history.History[0].Actor != "alice" {
+		t.Errorf("unexpected history %+v", history.History)
+	}
+}
+
+func TestUpdateShortURLMissingProtocol(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"url": "example.org"}`))
+	req.SetPathValue("url", "neBlT")
+	resp := httptest.NewRecorder()
+
+	server.UpdateShortURL(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected %v received %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestRedirectURLRecordsClicks(t *testing.T) {
+	mClicks := &mockClicks{}
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, cache.NewLRUCache(2), testCodec)
+	server.Clicks = mClicks
+
+	// the first request is served from the store, the second from the cache
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", "neBlT")
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("Referer", "https://news.example.com")
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		resp := httptest.NewRecorder()
+
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound {
+			t.Fatalf("expected %v received %v", http.StatusFound, resp.Code)
+		}
+	}
+
+	if len(mClicks.clicks) != 2 {
+		t.Fatalf("expected %v received %v", 2, len(mClicks.clicks))
+	}
+	for _, click := range mClicks.clicks {
+		if click.UrlID != id || click.Code != "neBlT" || click.IP != "10.0.0.1" || click.UserAgent != "test-agent" || click.Referer != "https://news.example.com" {
+			t.Errorf("unexpected click %+v", click)
+		}
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodGet, "/short/stats/neBlT?hours=48&days=7&top=5", nil)
+	req.SetPathValue("url", "neBlT")
+	resp := httptest.NewRecorder()
+
+	server.GetStats(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected %v received %v", http.StatusOK, resp.Code)
+	}
+
+	var stats model.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if stats.ShortUrl != "neBlT" || stats.LongUrl != expectedGetUrl || stats.TotalClicks != 3 || stats.UniqueVisitors != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(stats.Hourly) != 1 || len(stats.Daily) != 1 || len(stats.TopReferrers) != 1 || len(stats.TopUserAgents) != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestGetStatsInvalidQuery(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	for _, query := range []string{"hours=0", "days=1000", "top=abc"} {
+		req, _ := http.NewRequest(http.MethodGet, "/short/stats/neBlT?"+query, nil)
+		req.SetPathValue("url", "neBlT")
+		resp := httptest.NewRecorder()
+
+		server.GetStats(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %v received %v", query, http.StatusBadRequest, resp.Code)
+		}
+	}
+}
+
+func TestCreateAndRedirectWithMemoryStore(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+
+	longUrls := []string{"http://example.com", "http://example.org", "http://example.net"}
+	shortUrls := make([]string, len(longUrls))
+
+	for i, longUrl := range longUrls {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "`+longUrl+`"}`))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURL(resp, req)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected: %v received: %v", http.StatusCreated, resp.Code)
+		}
+
+		var created model.ShortUrlResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		shortUrls[i] = created.ShortUrl
+	}
+
+	if shortUrls[0] != "neBlT" {
+		t.Errorf("expected the first link to get ID 1, received: %v", shortUrls[0])
+	}
+
+	for i, shortUrl := range shortUrls {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", shortUrl)
+		resp := httptest.NewRecorder()
+
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound {
+			t.Errorf("expected %v received %v", http.StatusFound, resp.Code)
+		}
+		if resp.Header().Get("Location") != longUrls[i] {
+			t.Errorf("expected %v received %v", longUrls[i], resp.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCreateShortURLDedup(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079, Dedup: true}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+
+	tests := []struct {
+		body         string
+		expectedCode int
+		expectedUrl  string
+	}{
+		{`{"url": "http://example.com"}`, http.StatusCreated, "neBlT"},
+		{`{"url": "http://Example.com:80/"}`, http.StatusOK, "neBlT"},
+		// the request overrides the config
+		{`{"url": "http://example.com", "dedup": false}`, http.StatusCreated, testCodec.Encode(2)},
+		// a link with an alias is never reused
+		{`{"url": "http://example.com", "alias": "spring-sale"}`, http.StatusCreated, "spring-sale"},
+		{`{"url": "http://example.com"}`, http.StatusOK, "neBlT"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURL(resp, req)
+		if resp.Code != test.expectedCode {
+			t.Errorf("%s: expected: %v received: %v", test.body, test.expectedCode, resp.Code)
+		}
+
+		var created model.ShortUrlResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		if created.ShortUrl != test.expectedUrl {
+			t.Errorf("%s: expected: %v received: %v", test.body, test.expectedUrl, created.ShortUrl)
+		}
+	}
+}
+
+func TestCreateShortURLBatch(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+
+	body := `[
+		{"url": "http://example.com"},
+		{"url": "example.org"},
+		{"url": "http://example.org", "alias": "spring-sale"},
+		{"url": "http://example.net", "alias": "spring-sale"},
+		{"url": 42}
+	]`
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+
+	server.CreateShortURLBatch(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected: %v received: %v", http.StatusOK, resp.Code)
+	}
+
+	var response model.BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if response.Created != 2 || response.Failed != 3 || len(response.Results) != 5 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	expected := []model.BatchResult{
+		{Index: 0, LongUrl: "http://example.com", ShortUrl: "neBlT"},
+		{Index: 1, LongUrl: "example.org", Error: "Protocol Missing", Code: "missing_protocol"},
+		{Index: 2, LongUrl: "http://example.org", ShortUrl: "spring-sale"},
+		{Index: 3, LongUrl: "http://example.net", Error: "Alias already in use", Code: "alias_taken"},
+		{Index: 4, Error: "Invalid url object", Code: "invalid_body"},
+	}
+	if !reflect.DeepEqual(expected, response.Results) {
+		t.Errorf("expected: %+v received: %+v", expected, response.Results)
+	}
+}
+
+func TestCreateShortURLBatchDedup(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079, Dedup: true}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+
+	post := func(body string) model.BatchResponse {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		server.CreateShortURLBatch(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected: %v received: %v", http.StatusOK, resp.Code)
+		}
+		var response model.BatchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		return response
+	}
+
+	// a url repeated in the batch reuses the link made for it earlier in the batch
+	first := post(`[{"url": "http://example.com", "dedup": true}, {"url": "http://example.com"}, {"url": "http://example.com", "dedup": false}]`)
+	expected := []model.BatchResult{
+		{Index: 0, LongUrl: "http://example.com", ShortUrl: "neBlT"},
+		{Index: 1, LongUrl: "http://example.com", ShortUrl: "neBlT", Existing: true},
+		{Index: 2, LongUrl: "http://example.com", ShortUrl: "neBlZ"},
+	}
+	if first.Created != 2 || first.Existing != 1 || first.Failed != 0 || !reflect.DeepEqual(expected, first.Results) {
+		t.Errorf("unexpected response %+v", first)
+	}
+
+	second := post(`[{"url": "http://example.com", "dedup": true}]`)
+	expected = []model.BatchResult{{Index: 0, LongUrl: "http://example.com", ShortUrl: "neBlT", Existing: true}}
+	if second.Created != 0 || second.Existing != 1 || !reflect.DeepEqual(expected, second.Results) {
+		t.Errorf("unexpected response %+v", second)
+	}
+}
+
+// limitedConverter runs out of codes after max IDs like a feistel codec with few bits
+type limitedConverter struct {
+	url_converter.Converter
+	max int64
+}
+
+func (c limitedConverter) MaxID() int64 {
+	return c.max
+}
+
+func TestCreateShortURLCodesExhausted(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, cache.NewLRUCache(10), limitedConverter{testCodec, 1})
+
+	for _, expected := range []int{http.StatusCreated, http.StatusInsufficientStorage} {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "http://example.com"}`))
+		resp := httptest.NewRecorder()
+		server.CreateShortURL(resp, req)
+		if resp.Code != expected {
+			t.Errorf("expected: %v received: %v", expected, resp.Code)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"url": "http://example.com"}]`))
+	resp := httptest.NewRecorder()
+	server.CreateShortURLBatch(resp, req)
+	var response model.BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if response.Failed != 1 || response.Results[0].Code != "codes_exhausted" {
+		t.Errorf("unexpected response %+v", response)
+	}
+
+	// the links without a code are never created
+	for _, id := range []int64{2, 3} {
+		if _, err := memoryStore.Lookup(id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("expected link %v to be deleted received %v", id, err)
+		}
+	}
+}
+
+func TestCreateShortURLBatchNDJSON(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	body := "{\"url\": \"http://example.com\"}\n\nnot json\n{\"url\": \"http://example.org\", \"ttl_seconds\": -1}\n"
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+
+	server.CreateShortURLBatch(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected: %v received: %v", http.StatusOK, resp.Code)
+	}
+	if resp.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("expected: %v received: %v", "application/x-ndjson", resp.Header().Get("Content-Type"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected %v results, received: %q", 3, resp.Body.String())
+	}
+	expected := []model.BatchResult{
+		{Index: 0, LongUrl: "http://example.com", ShortUrl: "neBlT"},
+		{Index: 1, Error: "Invalid url object", Code: "invalid_body"},
+		{Index: 2, LongUrl: "http://example.org", Error: "ttl_seconds must be positive", Code: "invalid_expiry"},
+	}
+	for i, line := range lines {
+		var result model.BatchResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to unmarshal result %q: %v", line, err)
+		}
+		if !reflect.DeepEqual(expected[i], result) {
+			t.Errorf("expected: %+v received: %+v", expected[i], result)
+		}
+	}
+}
+
+func TestCreateShortURLBatchBadRequest(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	for _, body := range []string{"", "  \n", "[]", `[{"url": "http://example.com"}`} {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURLBatch(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected: %v received: %v", body, http.StatusBadRequest, resp.Code)
+		}
+	}
+}
+
+func TestCreateShortURLBatchTooLarge(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	bodies := []string{
+		"[" + strings.Repeat(" ", maxBatchBytes) + "]",
+		`{"url": "http://example.com"}` + strings.Repeat("\n", maxBatchBytes),
+	}
+	for _, body := range bodies {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+
+		server.CreateShortURLBatch(resp, req)
+		if resp.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected: %v received: %v", http.StatusRequestEntityTooLarge, resp.Code)
+		}
+
+		var problem model.Problem
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		if problem.Code != "request_too_large" {
+			t.Errorf("expected: %v received: %v", "request_too_large", problem.Code)
+		}
+	}
+}
+
+func TestMissingShortURLNotFound(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+
+	handlers := map[string]http.HandlerFunc{
+		"RedirectURL":     server.RedirectURL,
+		"DeleteShortURL":  server.DeleteShortURL,
+		"DisableShortURL": server.DisableShortURL,
+		"UpdateShortURL":  server.UpdateShortURL,
+		"GetHistory":      server.GetHistory,
+		"GetStats":        server.GetStats,
+	}
+	for name, handler := range handlers {
+		// a generated code and an alias that do not exist
+		for _, shortUrl := range []string{"neBlT", "spring-sale"} {
+			req, _ := http.NewRequest(http.MethodGet, "/", strings.NewReader(`{"url": "http://example.org"}`))
+			req.SetPathValue("url", shortUrl)
+			resp := httptest.NewRecorder()
+
+			handler(resp, req)
+			if resp.Code != http.StatusNotFound {
+				t.Errorf("%s %s: expected %v received %v", name, shortUrl, http.StatusNotFound, resp.Code)
+			}
+		}
+	}
+}
+
+func TestProblemFor(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{store.ErrNotFound, http.StatusNotFound, "not_found"},
+		{fmt.Errorf("lookup: %w", store.ErrNotFound), http.StatusNotFound, "not_found"},
+		{store.ErrExpired, http.StatusGone, "expired"},
+		{store.ErrDisabled, http.StatusGone, "disabled"},
+		{store.ErrAliasTaken, http.StatusConflict, "alias_taken"},
+		{store.ErrConflict, http.StatusConflict, "conflict"},
+		{badRequest(codeInvalidAlias, "bad alias"), http.StatusBadRequest, "invalid_alias"},
+		{errors.New("disk full"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, test := range tests {
+		problem := problemFor(test.err)
+		if problem.Status != test.status || problem.Code != test.code || problem.Title != http.StatusText(test.status) {
+			t.Errorf("%v: expected %v %v received %+v", test.err, test.status, test.code, problem)
+		}
+	}
+
+	if problem := problemFor(errors.New("disk full")); strings.Contains(problem.Detail, "disk") {
+		t.Errorf("expected internal details to be hidden, received %+v", problem)
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{XorSecretKey: 15489079}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "example.com"}`))
+	resp := httptest.NewRecorder()
+
+	server.CreateShortURL(resp, req)
+	if resp.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected %v received %v", "application/problem+json", resp.Header().Get("Content-Type"))
+	}
+
+	var problem model.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to unmarshal problem JSON: %v", err)
+	}
+	expected := model.Problem{
+		Type:   "urn:url-shortener:problem:missing_protocol",
+		Title:  "Bad Request",
+		Status: http.StatusBadRequest,
+		Detail: "Protocol Missing",
+		Code:   "missing_protocol",
+	}
+	if !reflect.DeepEqual(problem, expected) || resp.Code != http.StatusBadRequest {
+		t.Errorf("expected: %+v received: %v %+v", expected, resp.Code, problem)
+	}
+}
+
+func newTestRandomCodes() *store.RandomCodes {
+	return store.NewRandomCodes(func() (string, error) {
+		return url_converter.Base62.Random(url_converter.DefaultRandomCodeLength)
+	}, nil, url_converter.Base62.Normalize)
+}
+
+func TestCreateShortURLRandom(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{RandomCodes: true}, &mockLogger{}, cache.NewLRUCache(10), testCodec)
+	server.RandomCodes = newTestRandomCodes()
+
+	tests := []struct {
+		body   string
+		random bool
+	}{
+		{`{"url": "http://example.com"}`, true},
+		{`{"url": "http://example.com", "random": false}`, false},
+	}
+	for _, test := range tests {
+		body, random := test.body, test.random
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		server.CreateShortURL(resp, req)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("%v: expected %v received %v", body, http.StatusCreated, resp.Code)
+		}
+
+		var created model.ShortUrlResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		if random != (len(created.ShortUrl) == url_converter.DefaultRandomCodeLength) {
+			t.Errorf("%v: unexpected short url %v", body, created.ShortUrl)
+		}
+
+		req, _ = http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", created.ShortUrl)
+		resp = httptest.NewRecorder()
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound {
+			t.Errorf("%v: expected %v received %v", created.ShortUrl, http.StatusFound, resp.Code)
+		}
+	}
+
+	// the first link has a random code, the code of its ID does not reach it
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", testCodec.Encode(1))
+	resp := httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("expected %v received %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestRedirectURLRandomCodeSpelling(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := url_converter.NewPaddedCodec(url_converter.Crockford32, shuffleKey, 15489079, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lru := cache.NewLRUCache(10)
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, lru, codec)
+	server.RandomCodes = store.NewRandomCodes(func() (string, error) {
+		return url_converter.Crockford32.Random(url_converter.DefaultRandomCodeLength)
+	}, nil, url_converter.Crockford32.Normalize)
+
+	_, code, err := server.RandomCodes.Shorten(memoryStore, store.Link{LongUrl: expectedGetUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a random code read out over the phone is typed in lowercase, like the code of an ID
+	for _, shortUrl := range []string{strings.ToLower(code), code, strings.ToLower(code)} {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", shortUrl)
+		resp := httptest.NewRecorder()
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound {
+			t.Errorf("%v: expected %v received %v", shortUrl, http.StatusFound, resp.Code)
+		}
+	}
+
+	// every spelling is gone with the link
+	req, _ := http.NewRequest(http.MethodDelete, "/", nil)
+	req.SetPathValue("url", code)
+	server.DeleteShortURL(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("url", strings.ToLower(code))
+	resp := httptest.NewRecorder()
+	server.RedirectURL(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("expected %v received %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestCreateShortURLBatchRandom(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, &mockCache{}, testCodec)
+	server.RandomCodes = newTestRandomCodes()
+
+	body := `[{"url": "http://example.com/1", "random": true}, {"url": "http://example.com/2"}]`
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	server.CreateShortURLBatch(resp, req)
+
+	var response model.BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	if response.Created != 2 || len(response.Results[0].ShortUrl) != url_converter.DefaultRandomCodeLength ||
+		response.Results[1].ShortUrl != testCodec.Encode(2) {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
+
+func TestGetRandomCodeMetrics(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(memoryStore, http.NewServeMux(), &model.Config{RandomCodes: true}, &mockLogger{}, &mockCache{}, testCodec)
+	server.RandomCodes = newTestRandomCodes()
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "http://example.com"}`))
+	server.CreateShortURL(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest(http.MethodGet, "/short/metrics/random", nil)
+	resp := httptest.NewRecorder()
+	server.GetRandomCodeMetrics(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected %v received %v", http.StatusOK, resp.Code)
+	}
+
+	var metrics model.RandomCodeMetricsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		t.Fatalf("Failed to unmarshal response JSON: %v", err)
+	}
+	expected := model.RandomCodeMetricsResponse{Generated: 1}
+	if metrics != expected {
+		t.Errorf("expected %+v received %+v", expected, metrics)
+	}
+}
+
+func TestCreateShortURLRandomUnavailable(t *testing.T) {
+	server := NewServer(&mockStore{}, http.NewServeMux(), &model.Config{}, &mockLogger{}, &mockCache{}, testCodec)
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "http://example.com", "random": true}`))
+	resp := httptest.NewRecorder()
+	server.CreateShortURL(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected %v received %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestServersWithDifferentCodecs(t *testing.T) {
+	memoryStore, err := store.NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feistel, err := url_converter.NewFeistelCodec(url_converter.Base62, "feistel_key", url_converter.DefaultFeistelBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codecs := []url_converter.Converter{testCodec, url_converter.NewCodec("other_key", 42), feistel}
+
+	for _, codec := range codecs {
+		server := NewServer(memoryStore, http.NewServeMux(), &model.Config{}, &mockLogger{}, cache.NewLRUCache(10), codec)
+
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "http://example.com"}`))
+		resp := httptest.NewRecorder()
+		server.CreateShortURL(resp, req)
+
+		var created model.ShortUrlResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("Failed to unmarshal response JSON: %v", err)
+		}
+		id, err := codec.Decode(created.ShortUrl)
+		if err != nil {
+			t.Fatalf("expected a code of the server's codec, received %v: %v", created.ShortUrl, err)
+		}
+
+		req, _ = http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("url", codec.Encode(id))
+		resp = httptest.NewRecorder()
+		server.RedirectURL(resp, req)
+		if resp.Code != http.StatusFound {
+			t.Errorf("expected %v received %v", http.StatusFound, resp.Code)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package store
+
+import "errors"
+
+// The errors every Store returns for links, callers match them with errors.Is
+var (
+	ErrNotFound = errors.New("short URL not found")
+	ErrExpired  = errors.New("short URL has expired")
+	ErrDisabled = errors.New("short URL is disabled")
+	ErrConflict = errors.New("conflict")
+	// ErrCodesExhausted is returned instead of creating a link with an ID past its MaxID
+	ErrCodesExhausted = errors.New("no short codes left for new links")
+)
+
+// ErrAliasTaken is returned when another link already has the requested alias, it is an ErrConflict
+var ErrAliasTaken error = conflictError("alias already in use")
+
+// conflictError is a more specific ErrConflict
+type conflictError string
+
+func (e conflictError) Error() string {
+	return string(e)
+}
+
+func (e conflictError) Is(target error) bool {
+	return target == ErrConflict
+}
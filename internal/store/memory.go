@@ -0,0 +1,357 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process memory, it needs neither SQLite nor cgo.
+// If a snapshot file is set the data is loaded from it on start and written back on Close.
+type MemoryStore struct {
+	lock    sync.RWMutex
+	lastID  int64
+	links   map[int64]Link
+	aliases map[string]int64
+	// link IDs by urlHash of their long url, for ShortenDedup
+	hashes       map[string][]int64
+	history      map[int64][]Revision
+	clicks       map[int64][]Click
+	snapshotPath string
+}
+
+// memorySnapshot is the on-disk format of a MemoryStore
+type memorySnapshot struct {
+	LastID  int64                `json:"last_id"`
+	Links   []Link               `json:"links"`
+	History map[int64][]Revision `json:"history"`
+	Clicks  map[int64][]Click    `json:"clicks"`
+}
+
+// NewMemoryStore creates an empty store, or loads snapshotPath if it exists. An empty snapshotPath disables snapshots.
+func NewMemoryStore(snapshotPath string) (Store, error) {
+	store := &MemoryStore{
+		links:        map[int64]Link{},
+		aliases:      map[string]int64{},
+		hashes:       map[string][]int64{},
+		history:      map[int64][]Revision{},
+		clicks:       map[int64][]Click{},
+		snapshotPath: snapshotPath,
+	}
+
+	if snapshotPath == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("No snapshot found, starting empty")
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot memorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", snapshotPath, err)
+	}
+
+	store.lastID = snapshot.LastID
+	for _, link := range snapshot.Links {
+		store.links[link.ID] = link
+		if link.Alias != "" {
+			store.aliases[link.Alias] = link.ID
+		}
+		store.addHash(link)
+	}
+	for id, history := range snapshot.History {
+		store.history[id] = history
+	}
+	for id, clicks := range snapshot.Clicks {
+		store.clicks[id] = clicks
+	}
+	fmt.Println("Snapshot loaded!", "links:", len(store.links))
+
+	return store, nil
+}
+
+// Close writes the snapshot if one is configured
+func (m *MemoryStore) Close() {
+	if m.snapshotPath == "" {
+		return
+	}
+	if err := m.Snapshot(m.snapshotPath); err != nil {
+		fmt.Println("Failed to write snapshot:", err)
+	}
+}
+
+func (m *MemoryStore) SetStoreOptions() {
+}
+
+// Snapshot writes all the data to path, replacing the file atomically
+func (m *MemoryStore) Snapshot(path string) error {
+	m.lock.RLock()
+	snapshot := memorySnapshot{
+		LastID:  m.lastID,
+		Links:   make([]Link, 0, len(m.links)),
+		History: m.history,
+		Clicks:  m.clicks,
+	}
+	for _, link := range m.links {
+		snapshot.Links = append(snapshot.Links, link)
+	}
+	data, err := json.Marshal(snapshot)
+	m.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (m *MemoryStore) Shorten(link Link) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.shorten(link)
+}
+
+// ShortenDedup returns the ID of an existing active link for the same normalized url, or creates one
+// and reports created. Links with an alias or an expiry are never shared, so for those it is Shorten.
+func (m *MemoryStore) ShortenDedup(link Link) (int64, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if link.Alias == "" && link.ExpiresAt.IsZero() {
+		for _, id := range m.hashes[urlHash(link.LongUrl)] {
+			existing := m.links[id]
+			if existing.Alias == "" && existing.ExpiresAt.IsZero() && !existing.Disabled {
+				return id, false, nil
+			}
+		}
+	}
+
+	id, err := m.shorten(link)
+	return id, err == nil, err
+}
+
+// ShortenBatch creates all the links under one lock, a taken alias or an ID past MaxID only fails its own link
+func (m *MemoryStore) ShortenBatch(links []Link) ([]BatchResult, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	results := make([]BatchResult, len(links))
+	for i, link := range links {
+		results[i].ID, results[i].Err = m.shorten(link)
+	}
+	return results, nil
+}
+
+// shorten must be called with the write lock held
+func (m *MemoryStore) shorten(link Link) (int64, error) {
+	if link.Alias != "" {
+		if _, exists := m.aliases[link.Alias]; exists {
+			return 0, ErrAliasTaken
+		}
+	}
+	if err := checkMaxID(link, m.lastID+1); err != nil {
+		return 0, err
+	}
+
+	m.lastID++
+	link.ID = m.lastID
+	link.Disabled = false
+	link.MaxID = 0
+	m.links[link.ID] = link
+	if link.Alias != "" {
+		m.aliases[link.Alias] = link.ID
+	}
+	m.addHash(link)
+
+	return link.ID, nil
+}
+
+// addHash and removeHash keep the hashes index in step with links, IDs stay in ascending order
+func (m *MemoryStore) addHash(link Link) {
+	hash := urlHash(link.LongUrl)
+	ids := append(m.hashes[hash], link.ID)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	m.hashes[hash] = ids
+}
+
+func (m *MemoryStore) removeHash(link Link) {
+	hash := urlHash(link.LongUrl)
+	ids := m.hashes[hash]
+	for i, id := range ids {
+		if id == link.ID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(m.hashes, hash)
+	} else {
+		m.hashes[hash] = ids
+	}
+}
+
+func (m *MemoryStore) Lookup(shortCode int64) (Link, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	link, exists := m.links[shortCode]
+	if !exists {
+		return Link{}, ErrNotFound
+	}
+	return link, nil
+}
+
+// LookupAlias returns ErrNotFound if no link has the alias
+func (m *MemoryStore) LookupAlias(alias string) (Link, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	id, exists := m.aliases[alias]
+	if !exists {
+		return Link{}, ErrNotFound
+	}
+	return m.links[id], nil
+}
+
+// Delete removes the link and everything recorded for it, it returns ErrNotFound if there is no such link
+func (m *MemoryStore) Delete(id int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	link, exists := m.links[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	delete(m.links, id)
+	delete(m.aliases, link.Alias)
+	m.removeHash(link)
+	delete(m.history, id)
+	delete(m.clicks, id)
+	return nil
+}
+
+// SetDisabled returns ErrNotFound if there is no such link
+func (m *MemoryStore) SetDisabled(id int64, disabled bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	link, exists := m.links[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	link.Disabled = disabled
+	m.links[id] = link
+	return nil
+}
+
+// Update changes the destination of a link and records the previous one in its history,
+// it returns ErrNotFound if there is no such link
+func (m *MemoryStore) Update(id int64, longUrl string, actor string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	link, exists := m.links[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	m.history[id] = append(m.history[id], Revision{LongUrl: link.LongUrl, ChangedAt: time.Unix(time.Now().Unix(), 0).UTC(), Actor: actor})
+	m.removeHash(link)
+	link.LongUrl = longUrl
+	m.links[id] = link
+	m.addHash(link)
+	return nil
+}
+
+// History returns the previous destinations of a link, newest first
+func (m *MemoryStore) History(id int64) ([]Revision, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	revisions := m.history[id]
+	history := make([]Revision, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		history = append(history, revisions[i])
+	}
+	return history, nil
+}
+
+func (m *MemoryStore) RecordClicks(clicks []Click) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, click := range clicks {
+		m.clicks[click.UrlID] = append(m.clicks[click.UrlID], click)
+	}
+	return nil
+}
+
+// Stats aggregates the recorded clicks of a link, it does not check that the link exists
+func (m *MemoryStore) Stats(id int64, query StatsQuery) (Stats, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	clicks := m.clicks[id]
+	visitors := map[string]bool{}
+	hourly := map[int64]int64{}
+	daily := map[int64]int64{}
+	referrers := map[string]int64{}
+	userAgents := map[string]int64{}
+
+	for _, click := range clicks {
+		visitors[click.IP] = true
+		clickedAt := click.ClickedAt.Unix()
+		hourly[clickedAt-clickedAt%hourSeconds]++
+		daily[clickedAt-clickedAt%daySeconds]++
+		if click.Referer != "" {
+			referrers[click.Referer]++
+		}
+		if click.UserAgent != "" {
+			userAgents[click.UserAgent]++
+		}
+	}
+
+	now := time.Now()
+	return Stats{
+		TotalClicks:    int64(len(clicks)),
+		UniqueVisitors: int64(len(visitors)),
+		Hourly:         fillBuckets(hourly, hourSeconds, query.HourlySince, now),
+		Daily:          fillBuckets(daily, daySeconds, query.DailySince, now),
+		TopReferrers:   topCounts(referrers, query.Top),
+		TopUserAgents:  topCounts(userAgents, query.Top),
+	}, nil
+}
+
+// topCounts orders like the SQL stores, most clicks first and then by value
+func topCounts(clicks map[string]int64, limit int) []Count {
+	counts := make([]Count, 0, len(clicks))
+	for value, count := range clicks {
+		counts = append(counts, Count{Value: value, Clicks: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
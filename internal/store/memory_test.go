@@ -0,0 +1,292 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupMemoryStore(t *testing.T, snapshotPath string) Store {
+	t.Helper()
+
+	store, err := NewMemoryStore(snapshotPath)
+	if err != nil {
+		t.Fatalf("failed to create memory store: %v", err)
+	}
+	return store
+}
+
+func TestMemoryShortenAndLookup(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		id, err := store.Shorten(Link{LongUrl: fmt.Sprintf("https://example.com/%d", i)})
+		if err != nil {
+			t.Fatalf("failed to shorten URL: %v", err)
+		}
+		if id != i {
+			t.Errorf("expected %v, got %v", i, id)
+		}
+	}
+
+	link, err := store.Lookup(2)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+	if link.LongUrl != "https://example.com/2" {
+		t.Errorf("expected %v, got %v", "https://example.com/2", link.LongUrl)
+	}
+
+	if _, err := store.Lookup(4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestMemoryAliasDisableAndDelete(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "spring-sale"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+	if _, err := store.Shorten(Link{LongUrl: "https://example.org", Alias: "spring-sale"}); err != ErrAliasTaken {
+		t.Errorf("expected %v, got %v", ErrAliasTaken, err)
+	}
+
+	if err := store.SetDisabled(id, true); err != nil {
+		t.Fatalf("failed to disable URL: %v", err)
+	}
+	link, err := store.LookupAlias("spring-sale")
+	if err != nil {
+		t.Fatalf("failed to lookup alias: %v", err)
+	}
+	if link.ID != id || !link.Disabled {
+		t.Errorf("unexpected link %+v", link)
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("failed to delete URL: %v", err)
+	}
+	if _, err := store.LookupAlias("spring-sale"); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := store.Delete(id); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// IDs are never reused after a delete
+	next, err := store.Shorten(Link{LongUrl: "https://example.com"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+	if next != id+1 {
+		t.Errorf("expected %v, got %v", id+1, next)
+	}
+}
+
+func TestMemoryUpdateHistoryAndStats(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com/v1"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+	if err := store.Update(id, "https://example.com/v2", "alice"); err != nil {
+		t.Fatalf("failed to update URL: %v", err)
+	}
+	if err := store.Update(id, "https://example.com/v3", "bob"); err != nil {
+		t.Fatalf("failed to update URL: %v", err)
+	}
+
+	history, err := store.History(id)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history) != 2 || history[0].Actor != "bob" || history[1].LongUrl != "https://example.com/v1" {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	now := time.Now()
+	clicks := []Click{
+		{UrlID: id, Code: "ZxD7", ClickedAt: now, IP: "10.0.0.1", UserAgent: "curl", Referer: "https://news.example.com"},
+		{UrlID: id, Code: "ZxD7", ClickedAt: now, IP: "10.0.0.1", UserAgent: "curl", Referer: ""},
+		{UrlID: id, Code: "ZxD7", ClickedAt: now.AddDate(0, 0, -3), IP: "10.0.0.2", UserAgent: "firefox", Referer: "https://news.example.com"},
+	}
+	if err := store.RecordClicks(clicks); err != nil {
+		t.Fatalf("failed to record clicks: %v", err)
+	}
+
+	stats, err := store.Stats(id, StatsQuery{HourlySince: now.Add(-23 * time.Hour), DailySince: now.AddDate(0, 0, -6), Top: 1})
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if stats.TotalClicks != 3 || stats.UniqueVisitors != 2 {
+		t.Errorf("expected %v clicks from %v visitors, got %v from %v", 3, 2, stats.TotalClicks, stats.UniqueVisitors)
+	}
+	if len(stats.TopUserAgents) != 1 || stats.TopUserAgents[0] != (Count{Value: "curl", Clicks: 2}) {
+		t.Errorf("unexpected top user agents %+v", stats.TopUserAgents)
+	}
+	var daily int64
+	for _, bucket := range stats.Daily {
+		daily += bucket.Clicks
+	}
+	if daily != 3 {
+		t.Errorf("expected %v daily clicks, got %v", 3, daily)
+	}
+}
+
+func TestMemoryShortenDedup(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	first, created, err := store.ShortenDedup(Link{LongUrl: "https://example.com"})
+	if err != nil || !created {
+		t.Fatalf("expected a new link, got %v %v", created, err)
+	}
+	if id, created, err := store.ShortenDedup(Link{LongUrl: "HTTPS://example.com/"}); err != nil || created || id != first {
+		t.Errorf("expected %v, got %v %v %v", first, id, created, err)
+	}
+	if id, created, _ := store.ShortenDedup(Link{LongUrl: "https://example.com", ExpiresAt: time.Now().Add(time.Hour)}); !created || id == first {
+		t.Errorf("expected a new link with expiry, got %v %v", id, created)
+	}
+
+	// after an update the link is found by its new url only
+	if err := store.Update(first, "https://example.org", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if id, created, _ := store.ShortenDedup(Link{LongUrl: "https://example.org"}); created || id != first {
+		t.Errorf("expected %v, got %v %v", first, id, created)
+	}
+	if _, created, _ := store.ShortenDedup(Link{LongUrl: "https://example.com"}); !created {
+		t.Error("expected a new link for the previous url")
+	}
+
+	if err := store.Delete(first); err != nil {
+		t.Fatal(err)
+	}
+	if id, created, _ := store.ShortenDedup(Link{LongUrl: "https://example.org"}); !created || id == first {
+		t.Errorf("expected a new link after delete, got %v %v", id, created)
+	}
+}
+
+func TestMemoryShortenBatch(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	results, err := store.ShortenBatch([]Link{
+		{LongUrl: "https://example.com/1", Alias: "spring-sale"},
+		{LongUrl: "https://example.com/2", Alias: "spring-sale"},
+		{LongUrl: "https://example.com/3"},
+	})
+	if err != nil {
+		t.Fatalf("failed to shorten batch: %v", err)
+	}
+	if results[0].ID != 1 || results[1].Err != ErrAliasTaken || results[2].ID != 2 {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestMemoryShortenMaxID(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	results, err := store.ShortenBatch([]Link{
+		{LongUrl: "https://example.com/1", MaxID: 1},
+		{LongUrl: "https://example.com/2", MaxID: 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to shorten batch: %v", err)
+	}
+	if results[0].ID != 1 || results[1].Err != ErrCodesExhausted {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if id, err := store.Shorten(Link{LongUrl: "https://example.com/3"}); err != nil || id != 2 {
+		t.Errorf("expected %v, got %v %v", 2, id, err)
+	}
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	store := setupMemoryStore(t, snapshotPath)
+	first, _ := store.Shorten(Link{LongUrl: "https://example.com", Alias: "spring-sale", ExpiresAt: expiresAt})
+	second, _ := store.Shorten(Link{LongUrl: "https://example.org"})
+	store.Delete(second)
+	store.Update(first, "https://example.com/v2", "alice")
+	store.Close()
+
+	store = setupMemoryStore(t, snapshotPath)
+	defer store.Close()
+
+	link, err := store.LookupAlias("spring-sale")
+	if err != nil {
+		t.Fatalf("failed to lookup alias: %v", err)
+	}
+	if link.ID != first || link.LongUrl != "https://example.com/v2" || !link.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("unexpected link %+v", link)
+	}
+
+	history, err := store.History(first)
+	if err != nil || len(history) != 1 {
+		t.Errorf("unexpected history %+v %v", history, err)
+	}
+
+	// the ID sequence continues after the deleted link
+	id, err := store.Shorten(Link{LongUrl: "https://example.net"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+	if id != second+1 {
+		t.Errorf("expected %v, got %v", second+1, id)
+	}
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	store := setupMemoryStore(t, "")
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	numOperations := 2000
+	ids := make([]int64, numOperations)
+
+	for i := 0; i < numOperations; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			longURL := fmt.Sprintf("http://example.com/page%d", i)
+
+			id, err := store.Shorten(Link{LongUrl: longURL})
+			if err != nil {
+				t.Errorf("Failed to shorten URL: %v", err)
+				return
+			}
+			ids[i] = id
+
+			link, err := store.Lookup(id)
+			if err != nil {
+				t.Errorf("Failed to lookup URL %d: %v", id, err)
+				return
+			}
+			if link.LongUrl != longURL {
+				t.Errorf("Expected URL %s, but got %s", longURL, link.LongUrl)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if seen[id] || id < 1 || id > int64(numOperations) {
+			t.Fatalf("unexpected or duplicate id %v", id)
+		}
+		seen[id] = true
+	}
+}
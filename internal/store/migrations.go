@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Migration is one versioned step of the SQLite schema, Down reverts Up
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// sqliteMigrations must stay ordered by version, new schema changes are only ever appended
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create links",
+		Up: []string{`CREATE TABLE Short_Url_Service (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Long_url TEXT NOT NULL
+	)`},
+		Down: []string{`DROP TABLE Short_Url_Service`},
+	},
+	{
+		// unix seconds, NULL means the link never expires
+		Version: 2,
+		Name:    "link expiry",
+		Up:      []string{`ALTER TABLE Short_Url_Service ADD COLUMN Expires_at INTEGER`},
+		Down:    []string{`ALTER TABLE Short_Url_Service DROP COLUMN Expires_at`},
+	},
+	{
+		// every link has at most one alias and every alias is unique
+		Version: 3,
+		Name:    "aliases",
+		Up: []string{`CREATE TABLE Short_Url_Alias (
+		Alias TEXT PRIMARY KEY,
+		Url_id INTEGER NOT NULL UNIQUE REFERENCES Short_Url_Service(ID)
+	)`},
+		Down: []string{`DROP TABLE Short_Url_Alias`},
+	},
+	{
+		Version: 4,
+		Name:    "disabled links",
+		Up:      []string{`ALTER TABLE Short_Url_Service ADD COLUMN Disabled INTEGER NOT NULL DEFAULT 0`},
+		Down:    []string{`ALTER TABLE Short_Url_Service DROP COLUMN Disabled`},
+	},
+	{
+		// previous destinations of updated links
+		Version: 5,
+		Name:    "history",
+		Up: []string{`CREATE TABLE Short_Url_History (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Url_id INTEGER NOT NULL REFERENCES Short_Url_Service(ID),
+		Long_url TEXT NOT NULL,
+		Changed_at INTEGER NOT NULL,
+		Actor TEXT NOT NULL
+	)`,
+			`CREATE INDEX Short_Url_History_Url_id ON Short_Url_History (Url_id)`},
+		Down: []string{`DROP TABLE Short_Url_History`},
+	},
+	{
+		// one row per redirect, written in batches by the analytics recorder
+		Version: 6,
+		Name:    "clicks",
+		Up: []string{`CREATE TABLE Short_Url_Clicks (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Url_id INTEGER NOT NULL,
+		Code TEXT NOT NULL,
+		Clicked_at INTEGER NOT NULL,
+		Ip TEXT NOT NULL,
+		User_agent TEXT NOT NULL,
+		Referer TEXT NOT NULL
+	)`,
+			`CREATE INDEX Short_Url_Clicks_Url_id ON Short_Url_Clicks (Url_id, Clicked_at)`},
+		Down: []string{`DROP TABLE Short_Url_Clicks`},
+	},
+	{
+		// sha256 of the normalized url, filled in by the store for rows that predate it
+		Version: 7,
+		Name:    "url hash",
+		Up: []string{`ALTER TABLE Short_Url_Service ADD COLUMN Url_hash TEXT`,
+			`CREATE INDEX Short_Url_Service_Url_hash ON Short_Url_Service (Url_hash)`},
+		Down: []string{`DROP INDEX Short_Url_Service_Url_hash`,
+			`ALTER TABLE Short_Url_Service DROP COLUMN Url_hash`},
+	},
+	{
+		// random codes share the alias table, so they are unique among aliases as well
+		Version: 8,
+		Name:    "random codes",
+		Up:      []string{`ALTER TABLE Short_Url_Alias ADD COLUMN Random INTEGER NOT NULL DEFAULT 0`},
+		Down:    []string{`ALTER TABLE Short_Url_Alias DROP COLUMN Random`},
+	},
+}
+
+// Migrator applies sqliteMigrations and records every applied version in the schema_version table.
+// With DryRun set it only writes the SQL it would run to Out.
+type Migrator struct {
+	Db     *sql.DB
+	Out    io.Writer
+	DryRun bool
+}
+
+func NewMigrator(db *sql.DB, out io.Writer, dryRun bool) *Migrator {
+	return &Migrator{Db: db, Out: out, DryRun: dryRun}
+}
+
+// LatestVersion is the version a fully migrated database is at
+func LatestVersion() int {
+	return sqliteMigrations[len(sqliteMigrations)-1].Version
+}
+
+// Version returns the current schema version. Databases created before schema_version existed
+// are matched against the schema each migration produces.
+func (m *Migrator) Version() (int, error) {
+	exists, err := m.tableExists("schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return m.detectVersion()
+	}
+
+	var version int
+	err = m.Db.QueryRow(`SELECT COALESCE(MAX(Version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// Pending returns the migrations between the current version and target, in the order Up would apply them
+func (m *Migrator) Pending(target int) ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range sqliteMigrations {
+		if migration.Version > version && migration.Version <= target {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every migration up to and including target
+func (m *Migrator) Up(target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+
+	pending, err := m.Pending(target)
+	if err != nil {
+		return err
+	}
+	if err := m.init(); err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		if err := m.apply(migration, migration.Up, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts every migration above target, newest first
+func (m *Migrator) Down(target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if err := m.init(); err != nil {
+		return err
+	}
+
+	for i := len(sqliteMigrations) - 1; i >= 0; i-- {
+		migration := sqliteMigrations[i]
+		if migration.Version > version || migration.Version <= target {
+			continue
+		}
+		if err := m.apply(migration, migration.Down, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs the statements of one migration and its schema_version change in a single transaction
+func (m *Migrator) apply(migration Migration, statements []string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	fmt.Fprintf(m.Out, "-- %s %d: %s\n", direction, migration.Version, migration.Name)
+
+	if m.DryRun {
+		for _, statement := range statements {
+			fmt.Fprintf(m.Out, "%s;\n", statement)
+		}
+		return nil
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_version (Version, Name, Applied_at) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name, time.Now().Unix())
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_version WHERE Version = ?`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// init creates schema_version and stamps it with the detected version of an older database
+func (m *Migrator) init() error {
+	if m.DryRun {
+		return nil
+	}
+
+	exists, err := m.tableExists("schema_version")
+	if err != nil || exists {
+		return err
+	}
+
+	detected, err := m.detectVersion()
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TABLE schema_version (
+		Version INTEGER PRIMARY KEY,
+		Name TEXT NOT NULL,
+		Applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range sqliteMigrations {
+		if migration.Version > detected {
+			break
+		}
+		_, err := tx.Exec(`INSERT INTO schema_version (Version, Name, Applied_at) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name+" (detected)", time.Now().Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// detectVersion infers the version of a database that has no schema_version table yet,
+// every step checks for the table or column the matching migration creates.
+// Only migrations that predate schema_version need a check here.
+func (m *Migrator) detectVersion() (int, error) {
+	checks := []func() (bool, error){
+		func() (bool, error) { return m.tableExists("Short_Url_Service") },
+		func() (bool, error) { return m.columnExists("Short_Url_Service", "Expires_at") },
+		func() (bool, error) { return m.tableExists("Short_Url_Alias") },
+		func() (bool, error) { return m.columnExists("Short_Url_Service", "Disabled") },
+		func() (bool, error) { return m.tableExists("Short_Url_History") },
+		func() (bool, error) { return m.tableExists("Short_Url_Clicks") },
+	}
+
+	version := 0
+	for _, check := range checks {
+		exists, err := check()
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			break
+		}
+		version++
+	}
+	return version, nil
+}
+
+func (m *Migrator) tableExists(table string) (bool, error) {
+	var count int
+	err := m.Db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count)
+	return count > 0, err
+}
+
+func (m *Migrator) columnExists(table string, column string) (bool, error) {
+	var count int
+	err := m.Db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return count > 0, err
+}
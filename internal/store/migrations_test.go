@@ -0,0 +1,128 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "migrations.db")+"?mode=rwc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db := openTestDB(t)
+	var out bytes.Buffer
+	migrator := NewMigrator(db, &out, false)
+
+	if err := migrator.Up(LatestVersion()); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+	version, err := migrator.Version()
+	if err != nil || version != LatestVersion() {
+		t.Fatalf("expected version %v, got %v %v", LatestVersion(), version, err)
+	}
+
+	// a second run has nothing to do
+	out.Reset()
+	if err := migrator.Up(LatestVersion()); err != nil {
+		t.Fatalf("failed to migrate up again: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no migrations, got %q", out.String())
+	}
+
+	if err := migrator.Down(1); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	version, _ = migrator.Version()
+	if version != 1 {
+		t.Errorf("expected version %v, got %v", 1, version)
+	}
+	if exists, _ := migrator.tableExists("Short_Url_Alias"); exists {
+		t.Error("expected Short_Url_Alias to be dropped")
+	}
+	if exists, _ := migrator.columnExists("Short_Url_Service", "Expires_at"); exists {
+		t.Error("expected Expires_at to be dropped")
+	}
+
+	if err := migrator.Down(0); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	if exists, _ := migrator.tableExists("Short_Url_Service"); exists {
+		t.Error("expected Short_Url_Service to be dropped")
+	}
+
+	if err := migrator.Up(LatestVersion()); err != nil {
+		t.Fatalf("failed to migrate up from scratch: %v", err)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := openTestDB(t)
+	var out bytes.Buffer
+
+	if err := NewMigrator(db, &out, true).Up(LatestVersion()); err != nil {
+		t.Fatalf("failed to dry run: %v", err)
+	}
+	if !strings.Contains(out.String(), "CREATE TABLE Short_Url_Service") || !strings.Contains(out.String(), "-- up 2: link expiry") {
+		t.Errorf("expected the pending SQL, got %q", out.String())
+	}
+
+	migrator := NewMigrator(db, &out, false)
+	if exists, _ := migrator.tableExists("schema_version"); exists {
+		t.Error("expected dry run not to create schema_version")
+	}
+	if version, _ := migrator.Version(); version != 0 {
+		t.Errorf("expected version %v, got %v", 0, version)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	// the schema before any migration existed
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS Short_Url_Service (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Long_url TEXT NOT NULL
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO Short_Url_Service (Long_url) VALUES ('https://example.com')`); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	migrator := NewMigrator(db, &out, false)
+	if version, _ := migrator.Version(); version != 1 {
+		t.Fatalf("expected detected version %v, got %v", 1, version)
+	}
+	if err := migrator.Up(LatestVersion()); err != nil {
+		t.Fatalf("failed to migrate legacy database: %v", err)
+	}
+	if strings.Contains(out.String(), "-- up 1:") {
+		t.Errorf("expected the existing table to be kept, got %q", out.String())
+	}
+
+	store := &DB{Db: db}
+	link, err := store.Lookup(1)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+	if link.LongUrl != "https://example.com" || !link.ExpiresAt.IsZero() || link.Disabled {
+		t.Errorf("unexpected link %+v", link)
+	}
+	if _, err := store.Shorten(Link{LongUrl: "https://example.org", Alias: "spring-sale"}); err != nil {
+		t.Errorf("failed to shorten URL on migrated database: %v", err)
+	}
+}
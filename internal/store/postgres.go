@@ -0,0 +1,408 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresDB stores the links in PostgreSQL with the same schema as the SQLite store,
+// IDs come from a BIGSERIAL sequence so they keep increasing and stay encodable
+type PostgresDB struct {
+	Db *sql.DB
+}
+
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS Short_Url_Service (
+		ID BIGSERIAL PRIMARY KEY,
+		Long_url TEXT NOT NULL,
+		Expires_at BIGINT,
+		Disabled BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE TABLE IF NOT EXISTS Short_Url_Alias (
+		Alias TEXT PRIMARY KEY,
+		Url_id BIGINT NOT NULL UNIQUE REFERENCES Short_Url_Service(ID)
+	)`,
+	`CREATE TABLE IF NOT EXISTS Short_Url_History (
+		ID BIGSERIAL PRIMARY KEY,
+		Url_id BIGINT NOT NULL REFERENCES Short_Url_Service(ID),
+		Long_url TEXT NOT NULL,
+		Changed_at BIGINT NOT NULL,
+		Actor TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS Short_Url_History_Url_id ON Short_Url_History (Url_id)`,
+	`CREATE TABLE IF NOT EXISTS Short_Url_Clicks (
+		ID BIGSERIAL PRIMARY KEY,
+		Url_id BIGINT NOT NULL,
+		Code TEXT NOT NULL,
+		Clicked_at BIGINT NOT NULL,
+		Ip TEXT NOT NULL,
+		User_agent TEXT NOT NULL,
+		Referer TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS Short_Url_Clicks_Url_id ON Short_Url_Clicks (Url_id, Clicked_at)`,
+	`ALTER TABLE Short_Url_Service ADD COLUMN IF NOT EXISTS Url_hash TEXT`,
+	`CREATE INDEX IF NOT EXISTS Short_Url_Service_Url_hash ON Short_Url_Service (Url_hash)`,
+	`ALTER TABLE Short_Url_Alias ADD COLUMN IF NOT EXISTS Random BOOLEAN NOT NULL DEFAULT FALSE`,
+}
+
+func NewPostgresStore(dataSource string) (Store, error) {
+	fmt.Println("Attempting to open database...")
+	db, err := sql.Open("postgres", dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	fmt.Println("Database opened!")
+
+	for _, statement := range postgresSchema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	err = backfillUrlHashes(db, `SELECT ID, Long_url FROM Short_Url_Service WHERE Url_hash IS NULL`,
+		`UPDATE Short_Url_Service SET Url_hash = $1 WHERE ID = $2`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	fmt.Println("Table created or exists!")
+
+	return &PostgresDB{Db: db}, nil
+}
+
+func (d *PostgresDB) Close() {
+	d.Db.Close()
+}
+
+func (d *PostgresDB) SetStoreOptions() {
+	d.Db.SetMaxOpenConns(10)
+	d.Db.SetMaxIdleConns(5)
+}
+
+func (d *PostgresDB) Shorten(link Link) (int64, error) {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := postgresShorten(tx, link)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ShortenDedup returns the ID of an existing active link for the same normalized url, or creates one
+// and reports created. Links with an alias or an expiry are never shared, so for those it is Shorten.
+func (d *PostgresDB) ShortenDedup(link Link) (int64, bool, error) {
+	if link.Alias != "" || !link.ExpiresAt.IsZero() {
+		id, err := d.Shorten(link)
+		return id, err == nil, err
+	}
+
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// serializes concurrent requests for the same url, across every service instance
+	hash := urlHash(link.LongUrl)
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, hash); err != nil {
+		return 0, false, err
+	}
+
+	var id int64
+	created := false
+	err = tx.QueryRow(`SELECT s.ID FROM Short_Url_Service s LEFT JOIN Short_Url_Alias a ON a.Url_id = s.ID
+		WHERE s.Url_hash = $1 AND NOT s.Disabled AND s.Expires_at IS NULL AND a.Alias IS NULL
+		ORDER BY s.ID LIMIT 1`, hash).Scan(&id)
+	if err == sql.ErrNoRows {
+		id, err = postgresShorten(tx, link)
+		created = true
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+
+	return id, created, nil
+}
+
+// ShortenBatch creates all the links in a single transaction. A taken alias or an ID past MaxID only
+// fails its own link, any other error fails the whole batch and nothing is created.
+func (d *PostgresDB) ShortenBatch(links []Link) ([]BatchResult, error) {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BatchResult, len(links))
+	for i, link := range links {
+		if link.Alias == "" && link.MaxID == 0 {
+			results[i].ID, err = postgresShorten(tx, link)
+		} else {
+			results[i].ID, err = shortenSavepoint(tx, link, postgresShorten)
+		}
+		if errors.Is(err, ErrAliasTaken) || errors.Is(err, ErrCodesExhausted) {
+			results[i].Err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func postgresShorten(tx *sql.Tx, link Link) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`INSERT INTO Short_Url_Service (Long_url, Expires_at, Url_hash) VALUES ($1, $2, $3) RETURNING ID`,
+		link.LongUrl, toUnix(link.ExpiresAt), urlHash(link.LongUrl)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkMaxID(link, id); err != nil {
+		return 0, err
+	}
+
+	if link.Alias != "" {
+		_, err = tx.Exec(`INSERT INTO Short_Url_Alias (Alias, Url_id, Random) VALUES ($1, $2, $3)`, link.Alias, id, link.Random)
+		if err != nil {
+			if isPostgresUniqueViolation(err) {
+				return 0, ErrAliasTaken
+			}
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (d *PostgresDB) Lookup(shortCode int64) (Link, error) {
+	var link Link
+	var expiresAt sql.NullInt64
+	var alias sql.NullString
+	err := d.Db.QueryRow(`SELECT s.ID, s.Long_url, s.Expires_at, s.Disabled, a.Alias, COALESCE(a.Random, FALSE) FROM Short_Url_Service s
+		LEFT JOIN Short_Url_Alias a ON a.Url_id = s.ID WHERE s.ID = $1`, shortCode).Scan(&link.ID, &link.LongUrl, &expiresAt, &link.Disabled, &alias, &link.Random)
+	if err != nil {
+		return Link{}, notFound(err)
+	}
+	link.ExpiresAt = fromUnix(expiresAt)
+	link.Alias = alias.String
+	return link, nil
+}
+
+// LookupAlias returns ErrNotFound if no link has the alias
+func (d *PostgresDB) LookupAlias(alias string) (Link, error) {
+	link := Link{Alias: alias}
+	var expiresAt sql.NullInt64
+	err := d.Db.QueryRow(`SELECT s.ID, s.Long_url, s.Expires_at, s.Disabled, a.Random FROM Short_Url_Alias a
+		JOIN Short_Url_Service s ON s.ID = a.Url_id WHERE a.Alias = $1`, alias).Scan(&link.ID, &link.LongUrl, &expiresAt, &link.Disabled, &link.Random)
+	if err != nil {
+		return Link{}, notFound(err)
+	}
+	link.ExpiresAt = fromUnix(expiresAt)
+	return link, nil
+}
+
+// Delete removes the link and everything recorded for it, it returns ErrNotFound if there is no such link
+func (d *PostgresDB) Delete(id int64) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"Short_Url_Alias", "Short_Url_History", "Short_Url_Clicks"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE Url_id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM Short_Url_Service WHERE ID = $1`, id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetDisabled returns ErrNotFound if there is no such link
+func (d *PostgresDB) SetDisabled(id int64, disabled bool) error {
+	result, err := d.Db.Exec(`UPDATE Short_Url_Service SET Disabled = $1 WHERE ID = $2`, disabled, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// Update changes the destination of a link and records the previous one in its history,
+// it returns ErrNotFound if there is no such link
+func (d *PostgresDB) Update(id int64, longUrl string, actor string) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the row lock serializes concurrent updates of the same link
+	var previous string
+	if err := tx.QueryRow(`SELECT Long_url FROM Short_Url_Service WHERE ID = $1 FOR UPDATE`, id).Scan(&previous); err != nil {
+		return notFound(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO Short_Url_History (Url_id, Long_url, Changed_at, Actor) VALUES ($1, $2, $3, $4)`,
+		id, previous, time.Now().Unix(), actor)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE Short_Url_Service SET Long_url = $1, Url_hash = $2 WHERE ID = $3`, longUrl, urlHash(longUrl), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// History returns the previous destinations of a link, newest first
+func (d *PostgresDB) History(id int64) ([]Revision, error) {
+	rows, err := d.Db.Query(`SELECT Long_url, Changed_at, Actor FROM Short_Url_History
+		WHERE Url_id = $1 ORDER BY ID DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		var changedAt int64
+		if err := rows.Scan(&revision.LongUrl, &changedAt, &revision.Actor); err != nil {
+			return nil, err
+		}
+		revision.ChangedAt = time.Unix(changedAt, 0).UTC()
+		history = append(history, revision)
+	}
+
+	return history, rows.Err()
+}
+
+// RecordClicks stores a batch of clicks in a single transaction
+func (d *PostgresDB) RecordClicks(clicks []Click) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO Short_Url_Clicks (Url_id, Code, Clicked_at, Ip, User_agent, Referer) VALUES ($1, $2, $3, $4, $5, $6)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		if _, err := stmt.Exec(click.UrlID, click.Code, click.ClickedAt.Unix(), click.IP, click.UserAgent, click.Referer); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Stats aggregates the recorded clicks of a link, it does not check that the link exists
+func (d *PostgresDB) Stats(id int64, query StatsQuery) (Stats, error) {
+	var stats Stats
+	err := d.Db.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT Ip) FROM Short_Url_Clicks WHERE Url_id = $1`, id).
+		Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	now := time.Now()
+	if stats.Hourly, err = d.bucketClicks(id, hourSeconds, query.HourlySince, now); err != nil {
+		return Stats{}, err
+	}
+	if stats.Daily, err = d.bucketClicks(id, daySeconds, query.DailySince, now); err != nil {
+		return Stats{}, err
+	}
+
+	if stats.TopReferrers, err = d.topClicks(id, "Referer", query.Top); err != nil {
+		return Stats{}, err
+	}
+	if stats.TopUserAgents, err = d.topClicks(id, "User_agent", query.Top); err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func (d *PostgresDB) bucketClicks(id int64, size int64, since time.Time, now time.Time) ([]Bucket, error) {
+	rows, err := d.Db.Query(`SELECT Clicked_at - Clicked_at % $1 AS Bucket, COUNT(*) FROM Short_Url_Clicks
+		WHERE Url_id = $2 AND Clicked_at >= $3 GROUP BY Bucket`, size, id, since.Unix()-since.Unix()%size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clicks := map[int64]int64{}
+	for rows.Next() {
+		var start, count int64
+		if err := rows.Scan(&start, &count); err != nil {
+			return nil, err
+		}
+		clicks[start] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fillBuckets(clicks, size, since, now), nil
+}
+
+// column is never user input
+func (d *PostgresDB) topClicks(id int64, column string, limit int) ([]Count, error) {
+	rows, err := d.Db.Query(`SELECT `+column+`, COUNT(*) AS Clicks FROM Short_Url_Clicks
+		WHERE Url_id = $1 AND `+column+` != '' GROUP BY `+column+` ORDER BY Clicks DESC, `+column+` LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanCounts(rows)
+}
+
+func isPostgresUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
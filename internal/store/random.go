@@ -0,0 +1,169 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// maxRandomAttempts is how many codes are tried for one link before giving up, with codes long enough
+// to be unguessable a single collision is already rare
+const maxRandomAttempts = 10
+
+// ErrNoRandomCode is returned when every attempt to find a free random code for a link failed
+var ErrNoRandomCode = errors.New("no free random code found")
+
+// RandomCodes creates links that are only reachable by a random code. The codes are stored as the
+// alias of the link, a code that is already taken by another link or alias is replaced by a new one.
+type RandomCodes struct {
+	generate func() (string, error)
+	// reject reports codes that must not be used, like codes that also decode to a link ID
+	reject func(string) bool
+	// normalize folds every spelling of a code onto the one that is stored
+	normalize func(string) string
+
+	generated  atomic.Uint64
+	collisions atomic.Uint64
+	rejected   atomic.Uint64
+}
+
+// RandomCodeMetrics counts the generated codes and why some of them were not used
+type RandomCodeMetrics struct {
+	Generated  uint64
+	Collisions uint64
+	Rejected   uint64
+}
+
+// CollisionRate is the share of the generated codes that were already taken
+func (m RandomCodeMetrics) CollisionRate() float64 {
+	if m.Generated == 0 {
+		return 0
+	}
+	return float64(m.Collisions) / float64(m.Generated)
+}
+
+// NewRandomCodes creates the random code source of a store, reject and normalize may be nil
+func NewRandomCodes(generate func() (string, error), reject func(string) bool, normalize func(string) string) *RandomCodes {
+	return &RandomCodes{generate: generate, reject: reject, normalize: normalize}
+}
+
+// Normalize returns the spelling a random code is stored in, so a code typed in another spelling
+// of the alphabet, like a lowercase one, finds its link
+func (r *RandomCodes) Normalize(code string) string {
+	if r.normalize == nil {
+		return code
+	}
+	return r.normalize(code)
+}
+
+func (r *RandomCodes) Metrics() RandomCodeMetrics {
+	return RandomCodeMetrics{
+		Generated:  r.generated.Load(),
+		Collisions: r.collisions.Load(),
+		Rejected:   r.rejected.Load(),
+	}
+}
+
+// Shorten creates the link with a new random code and returns its ID and code
+func (r *RandomCodes) Shorten(store Store, link Link) (int64, string, error) {
+	link.Random = true
+	for attempt := 0; attempt < maxRandomAttempts; attempt++ {
+		code, err := r.next()
+		if err != nil {
+			return 0, "", err
+		}
+
+		link.Alias = code
+		id, err := store.Shorten(link)
+		if errors.Is(err, ErrAliasTaken) {
+			r.collisions.Add(1)
+			continue
+		}
+		return id, code, err
+	}
+	return 0, "", ErrNoRandomCode
+}
+
+// ShortenBatch is Store.ShortenBatch that gives the links with Random set a random code, it fills in
+// their Alias. The links whose code was taken are retried together in another batch.
+func (r *RandomCodes) ShortenBatch(store Store, links []Link) ([]BatchResult, error) {
+	pending := make([]int, 0, len(links))
+	for i := range links {
+		if links[i].Random {
+			pending = append(pending, i)
+		}
+	}
+	if err := r.assign(links, pending); err != nil {
+		return nil, err
+	}
+
+	results, err := store.ShortenBatch(links)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; attempt < maxRandomAttempts; attempt++ {
+		pending = pending[:0]
+		for i := range links {
+			if links[i].Random && errors.Is(results[i].Err, ErrAliasTaken) {
+				r.collisions.Add(1)
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			return results, nil
+		}
+
+		if err := r.assign(links, pending); err != nil {
+			return nil, err
+		}
+		retry := make([]Link, len(pending))
+		for j, i := range pending {
+			retry[j] = links[i]
+		}
+		retried, err := store.ShortenBatch(retry)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range pending {
+			results[i] = retried[j]
+		}
+	}
+
+	for i := range links {
+		if links[i].Random && errors.Is(results[i].Err, ErrAliasTaken) {
+			r.collisions.Add(1)
+			results[i].Err = ErrNoRandomCode
+		}
+	}
+	return results, nil
+}
+
+// assign gives the links at positions a new random code each
+func (r *RandomCodes) assign(links []Link, positions []int) error {
+	for _, i := range positions {
+		code, err := r.next()
+		if err != nil {
+			return err
+		}
+		links[i].Alias = code
+	}
+	return nil
+}
+
+// next returns a generated code that is not rejected
+func (r *RandomCodes) next() (string, error) {
+	for attempt := 0; attempt < maxRandomAttempts; attempt++ {
+		code, err := r.generate()
+		if err != nil {
+			return "", fmt.Errorf("generating a random code: %w", err)
+		}
+		r.generated.Add(1)
+		code = r.Normalize(code)
+		if r.reject == nil || !r.reject(code) {
+			return code, nil
+		}
+		r.rejected.Add(1)
+	}
+	return "", ErrNoRandomCode
+}
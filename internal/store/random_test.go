@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// scriptedCodes generates the given codes in order and then repeats the last one
+func scriptedCodes(codes ...string) func() (string, error) {
+	return func() (string, error) {
+		code := codes[0]
+		if len(codes) > 1 {
+			codes = codes[1:]
+		}
+		return code, nil
+	}
+}
+
+func TestRandomCodesShorten(t *testing.T) {
+	memory, err := NewMemoryStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]Store{"sqlite": setupTestDB(t, ":memory:"), "memory": memory} {
+		if _, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "taken"}); err != nil {
+			t.Fatalf("%v: failed to shorten URL: %v", name, err)
+		}
+
+		reject := func(code string) bool { return code == "decodes" }
+		random := NewRandomCodes(scriptedCodes("taken", "decodes", "fresh"), reject, nil)
+		id, code, err := random.Shorten(store, Link{LongUrl: "https://example.com/random"})
+		if err != nil || code != "fresh" {
+			t.Fatalf("%v: expected the code fresh, got %v %v", name, code, err)
+		}
+
+		metrics := random.Metrics()
+		if metrics != (RandomCodeMetrics{Generated: 3, Collisions: 1, Rejected: 1}) || metrics.CollisionRate() != 1.0/3 {
+			t.Errorf("%v: unexpected metrics %+v", name, metrics)
+		}
+
+		for _, lookup := range []func() (Link, error){
+			func() (Link, error) { return store.LookupAlias("fresh") },
+			func() (Link, error) { return store.Lookup(id) },
+		} {
+			link, err := lookup()
+			if err != nil || link.ID != id || link.Alias != "fresh" || !link.Random {
+				t.Errorf("%v: unexpected link %+v %v", name, link, err)
+			}
+		}
+		if link, err := store.LookupAlias("taken"); err != nil || link.Random {
+			t.Errorf("%v: expected a plain alias, got %+v %v", name, link, err)
+		}
+
+		// codes are stored in the spelling normalize gives
+		_, code, err = NewRandomCodes(scriptedCodes("lower"), nil, strings.ToUpper).Shorten(store, Link{LongUrl: "https://example.com/lower"})
+		if err != nil || code != "LOWER" {
+			t.Errorf("%v: expected the code LOWER, got %v %v", name, code, err)
+		}
+
+		// every code is taken
+		if _, _, err := NewRandomCodes(scriptedCodes("taken"), nil, nil).Shorten(store, Link{LongUrl: "https://example.com"}); !errors.Is(err, ErrNoRandomCode) {
+			t.Errorf("%v: expected %v, got %v", name, ErrNoRandomCode, err)
+		}
+		store.Close()
+	}
+}
+
+func TestRandomCodesShortenBatch(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	if _, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "taken"}); err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	random := NewRandomCodes(scriptedCodes("taken", "second", "first"), nil, nil)
+	links := []Link{
+		{LongUrl: "https://example.com/1", Random: true},
+		{LongUrl: "https://example.com/2"},
+		{LongUrl: "https://example.com/3", Random: true},
+		{LongUrl: "https://example.com/4", Alias: "taken"},
+	}
+	results, err := random.ShortenBatch(store, links)
+	if err != nil {
+		t.Fatalf("failed to shorten batch: %v", err)
+	}
+
+	// the taken random code is retried, the taken alias is not
+	if links[0].Alias != "first" || links[2].Alias != "second" || links[1].Alias != "" {
+		t.Errorf("unexpected codes %+v", links)
+	}
+	for i, result := range results[:3] {
+		if result.Err != nil || result.ID == 0 {
+			t.Errorf("link %v: unexpected result %+v", i, result)
+		}
+	}
+	if results[3].Err != ErrAliasTaken {
+		t.Errorf("expected %v, got %v", ErrAliasTaken, results[3].Err)
+	}
+	if link, err := store.LookupAlias("first"); err != nil || link.ID != results[0].ID || !link.Random {
+		t.Errorf("unexpected link %+v %v", link, err)
+	}
+	if metrics := random.Metrics(); metrics.Collisions != 1 || metrics.Generated != 3 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
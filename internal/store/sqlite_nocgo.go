@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package store
+
+// without cgo the SQLite driver cannot open a database, only the memory and postgres stores work
+func isUniqueViolation(err error) bool {
+	return false
+}
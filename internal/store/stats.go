@@ -0,0 +1,126 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	hourSeconds = int64(time.Hour / time.Second)
+	daySeconds  = int64(24 * time.Hour / time.Second)
+)
+
+// StatsQuery bounds the time series and top lists of Stats
+type StatsQuery struct {
+	HourlySince time.Time
+	DailySince  time.Time
+	Top         int
+}
+
+// Bucket is the number of clicks in the hour or day starting at Start (UTC)
+type Bucket struct {
+	Start  time.Time
+	Clicks int64
+}
+
+// Count is the number of clicks sharing a referrer or user agent
+type Count struct {
+	Value  string
+	Clicks int64
+}
+
+// Stats are the click aggregates of a single link
+type Stats struct {
+	TotalClicks    int64
+	UniqueVisitors int64
+	Hourly         []Bucket
+	Daily          []Bucket
+	TopReferrers   []Count
+	TopUserAgents  []Count
+}
+
+// Stats aggregates the recorded clicks of a link, it does not check that the link exists
+func (d *DB) Stats(id int64, query StatsQuery) (Stats, error) {
+	var stats Stats
+	err := d.Db.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT Ip) FROM Short_Url_Clicks WHERE Url_id = ?`, id).
+		Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	now := time.Now()
+	if stats.Hourly, err = d.bucketClicks(id, hourSeconds, query.HourlySince, now); err != nil {
+		return Stats{}, err
+	}
+	if stats.Daily, err = d.bucketClicks(id, daySeconds, query.DailySince, now); err != nil {
+		return Stats{}, err
+	}
+
+	// direct visits have no referrer and are not listed
+	if stats.TopReferrers, err = d.topClicks(id, "Referer", query.Top); err != nil {
+		return Stats{}, err
+	}
+	if stats.TopUserAgents, err = d.topClicks(id, "User_agent", query.Top); err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func (d *DB) bucketClicks(id int64, size int64, since time.Time, now time.Time) ([]Bucket, error) {
+	rows, err := d.Db.Query(`SELECT Clicked_at - Clicked_at % ? AS Bucket, COUNT(*) FROM Short_Url_Clicks
+		WHERE Url_id = ? AND Clicked_at >= ? GROUP BY Bucket`, size, id, since.Unix()-since.Unix()%size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clicks := map[int64]int64{}
+	for rows.Next() {
+		var start, count int64
+		if err := rows.Scan(&start, &count); err != nil {
+			return nil, err
+		}
+		clicks[start] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fillBuckets(clicks, size, since, now), nil
+}
+
+// column is never user input
+func (d *DB) topClicks(id int64, column string, limit int) ([]Count, error) {
+	rows, err := d.Db.Query(`SELECT `+column+`, COUNT(*) AS Clicks FROM Short_Url_Clicks
+		WHERE Url_id = ? AND `+column+` != '' GROUP BY `+column+` ORDER BY Clicks DESC, `+column+` LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanCounts(rows)
+}
+
+func scanCounts(rows *sql.Rows) ([]Count, error) {
+	defer rows.Close()
+
+	counts := []Count{}
+	for rows.Next() {
+		var count Count
+		if err := rows.Scan(&count.Value, &count.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// fillBuckets turns sparse per-bucket counts keyed by unix start into a continuous series from since to now,
+// so buckets without clicks are reported as zero
+func fillBuckets(clicks map[int64]int64, size int64, since time.Time, now time.Time) []Bucket {
+	buckets := []Bucket{}
+	for start := since.Unix() - since.Unix()%size; start <= now.Unix(); start += size {
+		buckets = append(buckets, Bucket{Start: time.Unix(start, 0).UTC(), Clicks: clicks[start]})
+	}
+	return buckets
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	now := time.Now()
+	clicks := []Click{
+		{UrlID: id, Code: "ZxD7", ClickedAt: now, IP: "10.0.0.1", UserAgent: "curl", Referer: "https://news.example.com"},
+		{UrlID: id, Code: "ZxD7", ClickedAt: now, IP: "10.0.0.1", UserAgent: "curl", Referer: ""},
+		{UrlID: id, Code: "ZxD7", ClickedAt: now.Add(-2 * time.Hour), IP: "10.0.0.2", UserAgent: "firefox", Referer: "https://news.example.com"},
+		{UrlID: id, Code: "ZxD7", ClickedAt: now.AddDate(0, 0, -3), IP: "10.0.0.3", UserAgent: "curl", Referer: "https://blog.example.com"},
+		// another link
+		{UrlID: id + 1, Code: "ZxD6", ClickedAt: now, IP: "10.0.0.4", UserAgent: "curl", Referer: ""},
+	}
+	if err := store.RecordClicks(clicks); err != nil {
+		t.Fatalf("failed to record clicks: %v", err)
+	}
+
+	stats, err := store.Stats(id, StatsQuery{HourlySince: now.Add(-23 * time.Hour), DailySince: now.AddDate(0, 0, -6), Top: 1})
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+
+	if stats.TotalClicks != 4 || stats.UniqueVisitors != 3 {
+		t.Errorf("expected %v clicks from %v visitors, got %v from %v", 4, 3, stats.TotalClicks, stats.UniqueVisitors)
+	}
+
+	if len(stats.Hourly) < 24 {
+		t.Fatalf("expected at least %v hourly buckets, got %v", 24, len(stats.Hourly))
+	}
+	var hourly int64
+	for _, bucket := range stats.Hourly {
+		if bucket.Start.Equal(now.Truncate(time.Hour)) && bucket.Clicks != 2 {
+			t.Errorf("expected %v clicks in the current hour, got %v", 2, bucket.Clicks)
+		}
+		hourly += bucket.Clicks
+	}
+	if hourly != 3 {
+		t.Errorf("expected %v clicks in the last day, got %v", 3, hourly)
+	}
+
+	if len(stats.Daily) < 7 {
+		t.Fatalf("expected at least %v daily buckets, got %v", 7, len(stats.Daily))
+	}
+	var daily int64
+	for _, bucket := range stats.Daily {
+		daily += bucket.Clicks
+	}
+	if daily != 4 {
+		t.Errorf("expected %v clicks in the last week, got %v", 4, daily)
+	}
+
+	if len(stats.TopReferrers) != 1 || stats.TopReferrers[0] != (Count{Value: "https://news.example.com", Clicks: 2}) {
+		t.Errorf("unexpected top referrers %+v", stats.TopReferrers)
+	}
+	if len(stats.TopUserAgents) != 1 || stats.TopUserAgents[0] != (Count{Value: "curl", Clicks: 3}) {
+		t.Errorf("unexpected top user agents %+v", stats.TopUserAgents)
+	}
+}
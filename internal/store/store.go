@@ -1,8 +1,12 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -11,9 +15,73 @@ type DB struct {
 	Db *sql.DB
 }
 
+// Link is a short link as it is persisted by a Store
+type Link struct {
+	ID      int64
+	LongUrl string
+	// zero if the link never expires
+	ExpiresAt time.Time
+	// optional vanity code, empty if the link has none
+	Alias string
+	// the alias is a random code generated for the link, the link is only reachable by it
+	Random bool
+	// disabled links are kept but no longer redirect
+	Disabled bool
+	// the largest ID the link may be created with, zero for any, it is not stored
+	MaxID int64
+}
+
+// Expired reports whether the link is past its expiry at the given time
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Check returns ErrExpired or ErrDisabled if the link must not redirect at the given time
+func (l Link) Check(now time.Time) error {
+	if l.Expired(now) {
+		return ErrExpired
+	}
+	if l.Disabled {
+		return ErrDisabled
+	}
+	return nil
+}
+
+// Revision is a previous destination of a link
+type Revision struct {
+	LongUrl   string
+	ChangedAt time.Time
+	Actor     string
+}
+
+// Click is a single successful redirect of a link
+type Click struct {
+	UrlID     int64
+	Code      string
+	ClickedAt time.Time
+	IP        string
+	UserAgent string
+	Referer   string
+}
+
+// BatchResult is the outcome of one link of a ShortenBatch, Err is set instead of ID if only that link failed
+type BatchResult struct {
+	ID  int64
+	Err error
+}
+
 type Store interface {
-	Shorten(string) (int64, error)
-	Lookup(int64) (string, error)
+	Shorten(Link) (int64, error)
+	ShortenDedup(Link) (int64, bool, error)
+	ShortenBatch([]Link) ([]BatchResult, error)
+	Lookup(int64) (Link, error)
+	LookupAlias(string) (Link, error)
+	Delete(int64) error
+	SetDisabled(int64, bool) error
+	Update(id int64, longUrl string, actor string) error
+	History(int64) ([]Revision, error)
+	RecordClicks([]Click) error
+	Stats(int64, StatsQuery) (Stats, error)
 	Close()
 	SetStoreOptions()
 }
@@ -36,11 +104,13 @@ func NewStore(dbPath string) (Store, error) {
 	}
 	fmt.Println("Database opened!")
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS Short_Url_Service (
-		ID INTEGER PRIMARY KEY AUTOINCREMENT,
-		Long_url TEXT NOT NULL
-	)`)
-	if err != nil {
+	if err := NewMigrator(db, os.Stdout, false).Up(LatestVersion()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := backfillUrlHashes(db, `SELECT ID, Long_url FROM Short_Url_Service WHERE Url_hash IS NULL`,
+		`UPDATE Short_Url_Service SET Url_hash = ? WHERE ID = ?`); err != nil {
+		db.Close()
 		return nil, err
 	}
 	// Enable WAL mode to allow for concurrent reads and a single write
@@ -49,13 +119,160 @@ func NewStore(dbPath string) (Store, error) {
 		return nil, err
 	}
 
-	fmt.Println("Table created or exists!")
+	fmt.Println("Schema is at version", LatestVersion())
 
 	return &DB{Db: db}, nil
 }
 
-func (d *DB) Shorten(longUrl string) (int64, error) {
-	result, err := d.Db.Exec(`INSERT INTO Short_Url_Service (Long_url) VALUES (?)`, longUrl)
+func (d *DB) Shorten(link Link) (int64, error) {
+	if link.Alias == "" && link.MaxID == 0 {
+		return shorten(d.Db, link)
+	}
+
+	// the link and its alias are created together or not at all, as is a link past its MaxID
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := shortenWithAlias(tx, link)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ShortenBatch creates all the links in a single transaction. A taken alias or an ID past MaxID only
+// fails its own link, any other error fails the whole batch and nothing is created.
+func (d *DB) ShortenBatch(links []Link) ([]BatchResult, error) {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BatchResult, len(links))
+	for i, link := range links {
+		if link.Alias == "" && link.MaxID == 0 {
+			results[i].ID, err = shorten(tx, link)
+		} else {
+			results[i].ID, err = shortenSavepoint(tx, link, shortenWithAlias)
+		}
+		if errors.Is(err, ErrAliasTaken) || errors.Is(err, ErrCodesExhausted) {
+			results[i].Err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// shortenSavepoint runs shorten in a savepoint, so a taken alias or an ID past MaxID only undoes its own link and the
+// transaction stays usable, PostgreSQL aborts the whole transaction on any failed statement otherwise
+func shortenSavepoint(tx *sql.Tx, link Link, shorten func(*sql.Tx, Link) (int64, error)) (int64, error) {
+	if _, err := tx.Exec(`SAVEPOINT batch_link`); err != nil {
+		return 0, err
+	}
+
+	id, err := shorten(tx, link)
+	if err != nil {
+		if _, rollbackErr := tx.Exec(`ROLLBACK TO SAVEPOINT batch_link`); rollbackErr != nil {
+			return 0, rollbackErr
+		}
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`RELEASE SAVEPOINT batch_link`); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// shortenWithAlias creates the link and its alias, if it has one
+func shortenWithAlias(tx *sql.Tx, link Link) (int64, error) {
+	id, err := shorten(tx, link)
+	if err != nil || link.Alias == "" {
+		return id, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO Short_Url_Alias (Alias, Url_id, Random) VALUES (?, ?, ?)`, link.Alias, id, link.Random)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrAliasTaken
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ShortenDedup returns the ID of an existing active link for the same normalized url, or creates one
+// and reports created. Links with an alias or an expiry are never shared, so for those it is Shorten.
+func (d *DB) ShortenDedup(link Link) (int64, bool, error) {
+	if link.Alias != "" || !link.ExpiresAt.IsZero() {
+		id, err := d.Shorten(link)
+		return id, err == nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := d.Db.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	// BEGIN IMMEDIATE takes the write lock up front, so two requests for the same url cannot both insert
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return 0, false, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	var id int64
+	created := false
+	err = conn.QueryRowContext(ctx, `SELECT s.ID FROM Short_Url_Service s LEFT JOIN Short_Url_Alias a ON a.Url_id = s.ID
+		WHERE s.Url_hash = ? AND s.Disabled = 0 AND s.Expires_at IS NULL AND a.Alias IS NULL
+		ORDER BY s.ID LIMIT 1`, urlHash(link.LongUrl)).Scan(&id)
+	if err == sql.ErrNoRows {
+		id, err = shorten(conn, link)
+		created = true
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return 0, false, err
+	}
+	committed = true
+
+	return id, created, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func shorten(db execer, link Link) (int64, error) {
+	result, err := db.ExecContext(context.Background(), `INSERT INTO Short_Url_Service (Long_url, Expires_at, Url_hash) VALUES (?, ?, ?)`,
+		link.LongUrl, toUnix(link.ExpiresAt), urlHash(link.LongUrl))
 	if err != nil {
 		return 0, err
 	}
@@ -65,17 +282,226 @@ func (d *DB) Shorten(longUrl string) (int64, error) {
 		return 0, err
 	}
 
+	if err := checkMaxID(link, id); err != nil {
+		return 0, err
+	}
+
 	return id, nil
 }
 
-func (d *DB) Lookup(shortCode int64) (string, error) {
-	var originalURL string
-	err := d.Db.QueryRow(`SELECT Long_url FROM Short_Url_Service WHERE  ID = ?`, shortCode).Scan(&originalURL)
+// checkMaxID fails a link inserted with an ID past its MaxID, the caller rolls the insert back
+func checkMaxID(link Link, id int64) error {
+	if link.MaxID != 0 && id > link.MaxID {
+		return ErrCodesExhausted
+	}
+	return nil
+}
+
+func (d *DB) Lookup(shortCode int64) (Link, error) {
+	var link Link
+	var expiresAt sql.NullInt64
+	var alias sql.NullString
+	err := d.Db.QueryRow(`SELECT s.ID, s.Long_url, s.Expires_at, s.Disabled, a.Alias, COALESCE(a.Random, 0) FROM Short_Url_Service s
+		LEFT JOIN Short_Url_Alias a ON a.Url_id = s.ID WHERE s.ID = ?`, shortCode).Scan(&link.ID, &link.LongUrl, &expiresAt, &link.Disabled, &alias, &link.Random)
+	if err != nil {
+		return Link{}, notFound(err)
+	}
+	link.ExpiresAt = fromUnix(expiresAt)
+	link.Alias = alias.String
+	return link, nil
+}
+
+// LookupAlias returns ErrNotFound if no link has the alias
+func (d *DB) LookupAlias(alias string) (Link, error) {
+	link := Link{Alias: alias}
+	var expiresAt sql.NullInt64
+	err := d.Db.QueryRow(`SELECT s.ID, s.Long_url, s.Expires_at, s.Disabled, a.Random FROM Short_Url_Alias a
+		JOIN Short_Url_Service s ON s.ID = a.Url_id WHERE a.Alias = ?`, alias).Scan(&link.ID, &link.LongUrl, &expiresAt, &link.Disabled, &link.Random)
+	if err != nil {
+		return Link{}, notFound(err)
+	}
+	link.ExpiresAt = fromUnix(expiresAt)
+	return link, nil
+}
+
+// Delete removes the link and its alias, it returns ErrNotFound if there is no such link
+func (d *DB) Delete(id int64) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM Short_Url_Alias WHERE Url_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM Short_Url_History WHERE Url_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM Short_Url_Clicks WHERE Url_id = ?`, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM Short_Url_Service WHERE ID = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetDisabled returns ErrNotFound if there is no such link
+func (d *DB) SetDisabled(id int64, disabled bool) error {
+	result, err := d.Db.Exec(`UPDATE Short_Url_Service SET Disabled = ? WHERE ID = ?`, disabled, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// Update changes the destination of a link and records the previous one in its history,
+// it returns ErrNotFound if there is no such link
+func (d *DB) Update(id int64, longUrl string, actor string) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	if err := tx.QueryRow(`SELECT Long_url FROM Short_Url_Service WHERE ID = ?`, id).Scan(&previous); err != nil {
+		return notFound(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO Short_Url_History (Url_id, Long_url, Changed_at, Actor) VALUES (?, ?, ?, ?)`,
+		id, previous, time.Now().Unix(), actor)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE Short_Url_Service SET Long_url = ?, Url_hash = ? WHERE ID = ?`, longUrl, urlHash(longUrl), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// History returns the previous destinations of a link, newest first
+func (d *DB) History(id int64) ([]Revision, error) {
+	rows, err := d.Db.Query(`SELECT Long_url, Changed_at, Actor FROM Short_Url_History
+		WHERE Url_id = ? ORDER BY ID DESC`, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("short URL not found")
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		var changedAt int64
+		if err := rows.Scan(&revision.LongUrl, &changedAt, &revision.Actor); err != nil {
+			return nil, err
+		}
+		revision.ChangedAt = time.Unix(changedAt, 0).UTC()
+		history = append(history, revision)
+	}
+
+	return history, rows.Err()
+}
+
+// RecordClicks stores a batch of clicks in a single transaction
+func (d *DB) RecordClicks(clicks []Click) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO Short_Url_Clicks (Url_id, Code, Clicked_at, Ip, User_agent, Referer) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		if _, err := stmt.Exec(click.UrlID, click.Code, click.ClickedAt.Unix(), click.IP, click.UserAgent, click.Referer); err != nil {
+			return err
 		}
-		return "", err
 	}
-	return originalURL, nil
+
+	return tx.Commit()
+}
+
+// backfillUrlHashes hashes the rows created before Url_hash existed, so dedup finds them too
+func backfillUrlHashes(db *sql.DB, selectQuery string, updateQuery string) error {
+	rows, err := db.Query(selectQuery)
+	if err != nil {
+		return err
+	}
+
+	hashes := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var longUrl string
+		if err := rows.Scan(&id, &longUrl); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes[id] = urlHash(longUrl)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(hashes) == 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, hash := range hashes {
+		if _, err := tx.Exec(updateQuery, hash, id); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Url hashes filled in:", len(hashes))
+	return tx.Commit()
+}
+
+// notFound turns the sql.ErrNoRows of a missing link into ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func toUnix(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+func fromUnix(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(n.Int64, 0).UTC()
 }
@@ -1,9 +1,11 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -26,7 +28,7 @@ func TestShortenAndLookup(t *testing.T) {
 
 	longURL := "https://example.com"
 
-	id, err := store.Shorten(longURL)
+	id, err := store.Shorten(Link{LongUrl: longURL})
 	if err != nil {
 		t.Fatalf("failed to shorten URL: %v", err)
 	}
@@ -35,13 +37,43 @@ func TestShortenAndLookup(t *testing.T) {
 		t.Errorf("expected %v, got %v", 1, id)
 	}
 
-	retrievedURL, err := store.Lookup(id)
+	link, err := store.Lookup(id)
 	if err != nil {
 		t.Fatalf("failed to lookup URL: %v", err)
 	}
 
-	if retrievedURL != longURL {
-		t.Errorf("expected %v, got %v", longURL, retrievedURL)
+	if link.LongUrl != longURL {
+		t.Errorf("expected %v, got %v", longURL, link.LongUrl)
+	}
+	if !link.ExpiresAt.IsZero() {
+		t.Errorf("expected no expiry, got %v", link.ExpiresAt)
+	}
+}
+
+func TestShortenWithExpiry(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com", ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	link, err := store.Lookup(id)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+
+	if !link.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected %v, got %v", expiresAt, link.ExpiresAt)
+	}
+	if link.Expired(time.Now()) {
+		t.Error("expected link to be active")
+	}
+	if !link.Expired(expiresAt) {
+		t.Error("expected link to be expired at its expiry time")
 	}
 }
 
@@ -50,8 +82,317 @@ func TestLookupNonexistentCode(t *testing.T) {
 	defer service.Close()
 
 	_, err := service.Lookup(2)
-	if err == nil || err.Error() != "short URL not found" {
-		t.Errorf("expected 'short URL not found' error, got %v", err)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestLinkCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		link     Link
+		expected error
+	}{
+		{Link{}, nil},
+		{Link{ExpiresAt: now.Add(time.Hour)}, nil},
+		{Link{ExpiresAt: now}, ErrExpired},
+		{Link{Disabled: true}, ErrDisabled},
+		{Link{ExpiresAt: now.Add(-time.Hour), Disabled: true}, ErrExpired},
+	}
+
+	for _, test := range tests {
+		if err := test.link.Check(now); err != test.expected {
+			t.Errorf("%+v: expected %v, got %v", test.link, test.expected, err)
+		}
+	}
+
+	if !errors.Is(ErrAliasTaken, ErrConflict) {
+		t.Errorf("expected %v to be a %v", ErrAliasTaken, ErrConflict)
+	}
+}
+
+func TestShortenWithAlias(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "spring-sale"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	link, err := store.LookupAlias("spring-sale")
+	if err != nil {
+		t.Fatalf("failed to lookup alias: %v", err)
+	}
+	if link.ID != id || link.LongUrl != "https://example.com" {
+		t.Errorf("expected %v %v, got %v %v", id, "https://example.com", link.ID, link.LongUrl)
+	}
+
+	link, err = store.Lookup(id)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+	if link.Alias != "spring-sale" {
+		t.Errorf("expected %v, got %v", "spring-sale", link.Alias)
+	}
+
+	if _, err := store.Shorten(Link{LongUrl: "https://example.org", Alias: "spring-sale"}); err != ErrAliasTaken {
+		t.Errorf("expected %v, got %v", ErrAliasTaken, err)
+	}
+
+	if _, err := store.LookupAlias("summer-sale"); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestDisableAndDelete(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "spring-sale"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	if err := store.SetDisabled(id, true); err != nil {
+		t.Fatalf("failed to disable URL: %v", err)
+	}
+	link, err := store.Lookup(id)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+	if !link.Disabled {
+		t.Error("expected link to be disabled")
+	}
+
+	if err := store.SetDisabled(id, false); err != nil {
+		t.Fatalf("failed to enable URL: %v", err)
+	}
+	link, err = store.LookupAlias("spring-sale")
+	if err != nil {
+		t.Fatalf("failed to lookup alias: %v", err)
+	}
+	if link.Disabled {
+		t.Error("expected link to be enabled")
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("failed to delete URL: %v", err)
+	}
+	if _, err := store.Lookup(id); err == nil {
+		t.Error("expected deleted link to be gone")
+	}
+	if _, err := store.LookupAlias("spring-sale"); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := store.Delete(id); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := store.SetDisabled(id, true); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestUpdateAndHistory(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com/v1"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	if err := store.Update(id, "https://example.com/v2", "alice"); err != nil {
+		t.Fatalf("failed to update URL: %v", err)
+	}
+	if err := store.Update(id, "https://example.com/v3", "bob"); err != nil {
+		t.Fatalf("failed to update URL: %v", err)
+	}
+
+	link, err := store.Lookup(id)
+	if err != nil {
+		t.Fatalf("failed to lookup URL: %v", err)
+	}
+	if link.LongUrl != "https://example.com/v3" {
+		t.Errorf("expected %v, got %v", "https://example.com/v3", link.LongUrl)
+	}
+
+	history, err := store.History(id)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected %v revisions, got %v", 2, len(history))
+	}
+	if history[0].LongUrl != "https://example.com/v2" || history[0].Actor != "bob" {
+		t.Errorf("unexpected latest revision %+v", history[0])
+	}
+	if history[1].LongUrl != "https://example.com/v1" || history[1].Actor != "alice" {
+		t.Errorf("unexpected oldest revision %+v", history[1])
+	}
+
+	if err := store.Update(id+1, "https://example.com", "alice"); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestShortenDedup(t *testing.T) {
+	// a file so that every connection of the pool sees the same database
+	store := setupTestDB(t, "file:"+filepath.Join(t.TempDir(), "dedup.db")+"?mode=rwc")
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	ids := make([]int64, 20)
+	created := make([]bool, len(ids))
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			ids[i], created[i], err = store.ShortenDedup(Link{LongUrl: "https://Example.com:443"})
+			if err != nil {
+				t.Errorf("failed to shorten URL: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i, id := range ids {
+		if id != ids[0] {
+			t.Errorf("expected every request to get %v, got %v", ids[0], id)
+		}
+		if created[i] {
+			createdCount++
+		}
+	}
+	if createdCount != 1 {
+		t.Errorf("expected %v created link, got %v", 1, createdCount)
+	}
+
+	// links with an alias or an expiry are neither reused nor shared
+	aliased, isNew, err := store.ShortenDedup(Link{LongUrl: "https://example.com/", Alias: "spring-sale"})
+	if err != nil || !isNew || aliased == ids[0] {
+		t.Errorf("expected a new aliased link, got %v %v %v", aliased, isNew, err)
+	}
+
+	// a disabled link is not handed out again, and an updated link is found by its new url
+	if err := store.SetDisabled(ids[0], true); err != nil {
+		t.Fatal(err)
+	}
+	fresh, isNew, err := store.ShortenDedup(Link{LongUrl: "https://example.com"})
+	if err != nil || !isNew || fresh == ids[0] {
+		t.Errorf("expected a new link, got %v %v %v", fresh, isNew, err)
+	}
+	if err := store.Update(fresh, "https://example.org", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if id, isNew, err := store.ShortenDedup(Link{LongUrl: "https://example.org"}); err != nil || isNew || id != fresh {
+		t.Errorf("expected %v, got %v %v %v", fresh, id, isNew, err)
+	}
+}
+
+func TestShortenBatch(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	if _, err := store.Shorten(Link{LongUrl: "https://example.com", Alias: "taken"}); err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	results, err := store.ShortenBatch([]Link{
+		{LongUrl: "https://example.com/1"},
+		{LongUrl: "https://example.com/2", Alias: "taken"},
+		{LongUrl: "https://example.com/3", Alias: "spring-sale"},
+		{LongUrl: "https://example.com/4", Alias: "spring-sale"},
+		{LongUrl: "https://example.com/5"},
+	})
+	if err != nil {
+		t.Fatalf("failed to shorten batch: %v", err)
+	}
+
+	for i, result := range results {
+		failed := i == 1 || i == 3
+		if failed && result.Err != ErrAliasTaken {
+			t.Errorf("link %v: expected %v, got %v", i, ErrAliasTaken, result.Err)
+		}
+		if !failed && (result.Err != nil || result.ID == 0) {
+			t.Errorf("link %v: unexpected result %+v", i, result)
+		}
+	}
+
+	// the failed links left nothing behind
+	link, err := store.LookupAlias("spring-sale")
+	if err != nil || link.ID != results[2].ID || link.LongUrl != "https://example.com/3" {
+		t.Errorf("unexpected link %+v %v", link, err)
+	}
+	var count int
+	if err := store.(*DB).Db.QueryRow(`SELECT COUNT(*) FROM Short_Url_Service`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("expected %v links, got %v", 4, count)
+	}
+}
+
+func TestShortenMaxID(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	if id, err := store.Shorten(Link{LongUrl: "https://example.com", MaxID: 1}); err != nil || id != 1 {
+		t.Fatalf("expected %v, got %v %v", 1, id, err)
+	}
+	if _, err := store.Shorten(Link{LongUrl: "https://example.com/1", MaxID: 1}); err != ErrCodesExhausted {
+		t.Errorf("expected %v, got %v", ErrCodesExhausted, err)
+	}
+	if _, _, err := store.ShortenDedup(Link{LongUrl: "https://example.com/2", MaxID: 1}); err != ErrCodesExhausted {
+		t.Errorf("expected %v, got %v", ErrCodesExhausted, err)
+	}
+	results, err := store.ShortenBatch([]Link{
+		{LongUrl: "https://example.com/3", MaxID: 1},
+		{LongUrl: "https://example.com/4", Random: true, Alias: "x7Kp2mQa"},
+	})
+	if err != nil {
+		t.Fatalf("failed to shorten batch: %v", err)
+	}
+	if results[0].Err != ErrCodesExhausted || results[1].Err != nil {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	// the refused links were never created
+	var count int
+	if err := store.(*DB).Db.QueryRow(`SELECT COUNT(*) FROM Short_Url_Service`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected %v links, got %v", 2, count)
+	}
+}
+
+func TestRecordClicks(t *testing.T) {
+	store := setupTestDB(t, ":memory:")
+	defer store.Close()
+
+	id, err := store.Shorten(Link{LongUrl: "https://example.com"})
+	if err != nil {
+		t.Fatalf("failed to shorten URL: %v", err)
+	}
+
+	clicks := []Click{
+		{UrlID: id, Code: "ZxD7", ClickedAt: time.Now(), IP: "10.0.0.1", UserAgent: "curl", Referer: ""},
+		{UrlID: id, Code: "ZxD7", ClickedAt: time.Now(), IP: "10.0.0.2", UserAgent: "firefox", Referer: "https://news.example.com"},
+	}
+	if err := store.RecordClicks(clicks); err != nil {
+		t.Fatalf("failed to record clicks: %v", err)
+	}
+
+	var count int
+	if err := store.(*DB).Db.QueryRow(`SELECT COUNT(*) FROM Short_Url_Clicks WHERE Url_id = ?`, id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(clicks) {
+		t.Errorf("expected %v, got %v", len(clicks), count)
 	}
 }
 
@@ -72,7 +413,7 @@ func TestMixedConcurrentAccess(t *testing.T) {
 			longURL := fmt.Sprintf("%s%d", longURLBase, i)
 			shortCode := fmt.Sprintf("code%d", i)
 
-			id, err := service.Shorten(longURL)
+			id, err := service.Shorten(Link{LongUrl: longURL})
 			if err != nil {
 				t.Errorf("Failed to shorten URL: %v", err)
 				return
@@ -80,14 +421,14 @@ func TestMixedConcurrentAccess(t *testing.T) {
 
 			time.Sleep(time.Millisecond * 10)
 
-			retrievedURL, err := service.Lookup(id)
+			link, err := service.Lookup(id)
 			if err != nil {
 				t.Errorf("Failed to lookup URL for code %s: %v", shortCode, err)
 				return
 			}
 
-			if retrievedURL != longURL {
-				t.Errorf("Expected URL %s, but got %s for code %s", longURL, retrievedURL, shortCode)
+			if link.LongUrl != longURL {
+				t.Errorf("Expected URL %s, but got %s for code %s", longURL, link.LongUrl, shortCode)
 			}
 		}(i)
 	}
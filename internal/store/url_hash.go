@@ -0,0 +1,41 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// normalizeURL maps equivalent spellings of a url to one form: lower case scheme and host,
+// no default port and "/" for an empty path. The fragment is kept, single page apps route with it.
+// Unparsable urls are kept as they are.
+func normalizeURL(rawUrl string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || parsed.Host == "" {
+		return rawUrl
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	parsed.Host = host
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	return parsed.String()
+}
+
+// urlHash is the indexed key used to find an existing link for the same url
+func urlHash(longUrl string) string {
+	hash := sha256.Sum256([]byte(normalizeURL(longUrl)))
+	return hex.EncodeToString(hash[:])
+}
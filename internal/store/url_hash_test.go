@@ -0,0 +1,29 @@
+package store
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"HTTP://Example.COM":              "http://example.com/",
+		"http://example.com:80/a?b=c#top": "http://example.com/a?b=c#top",
+		"https://example.com:443/":        "https://example.com/",
+		"https://example.com:8443/Path":   "https://example.com:8443/Path",
+		" http://example.com/ ":           "http://example.com/",
+	}
+
+	for raw, expected := range tests {
+		if normalized := normalizeURL(raw); normalized != expected {
+			t.Errorf("normalizeURL(%q) = %q; want %q", raw, normalized, expected)
+		}
+	}
+
+	if urlHash("http://EXAMPLE.com") != urlHash("http://example.com/") {
+		t.Error("expected equivalent urls to have the same hash")
+	}
+	if urlHash("http://example.com/a") == urlHash("http://example.com/A") {
+		t.Error("expected paths to stay case sensitive")
+	}
+	if urlHash("https://app.example.com/#/settings") == urlHash("https://app.example.com/#/orders") {
+		t.Error("expected fragments to tell urls apart")
+	}
+}
@@ -0,0 +1,173 @@
+package url_converter
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Alphabet is the character set of short codes. Its fold function maps the characters a reader
+// may type instead of a character of the set onto that character, before a code is decoded.
+type Alphabet struct {
+	name      string
+	chars     string
+	fold      func(byte) byte
+	maxLength int
+}
+
+// The alphabets to choose from. Crockford32 and Base36 are case-insensitive and Crockford32 also
+// reads O as 0 and I and L as 1, Base57 leaves out 0, O, 1, I and l.
+var (
+	Base62      = newAlphabet("base62", base62Chars, nil)
+	Base36      = newAlphabet("base36", "0123456789abcdefghijklmnopqrstuvwxyz", foldBase36)
+	Crockford32 = newAlphabet("crockford32", "0123456789ABCDEFGHJKMNPQRSTVWXYZ", foldCrockford)
+	Base57      = newAlphabet("base57", "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz", nil)
+)
+
+var alphabets = []*Alphabet{Base62, Base36, Crockford32, Base57}
+
+func newAlphabet(name string, chars string, fold func(byte) byte) *Alphabet {
+	alphabet := &Alphabet{name: name, chars: chars, fold: fold}
+	alphabet.maxLength = len(alphabet.encode(math.MaxInt64))
+	return alphabet
+}
+
+// AlphabetByName returns the alphabet for the alphabet option of the config, base62 if name is empty
+func AlphabetByName(name string) (*Alphabet, error) {
+	if name == "" {
+		return Base62, nil
+	}
+	for _, alphabet := range alphabets {
+		if alphabet.name == name {
+			return alphabet, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown alphabet %q", name)
+}
+
+func (a *Alphabet) String() string {
+	return a.name
+}
+
+// MaxLength is the length of the code of the largest ID, the longest code a Codec makes
+func (a *Alphabet) MaxLength() int {
+	return a.maxLength
+}
+
+// Normalize returns the code with every character folded onto the one of the alphabet it stands for
+func (a *Alphabet) Normalize(code string) string {
+	if a.fold == nil {
+		return code
+	}
+	folded := []byte(code)
+	for i := range folded {
+		folded[i] = a.fold(folded[i])
+	}
+	return string(folded)
+}
+
+// DefaultRandomCodeLength gives random codes of at least 80 bits with every alphabet, and they are
+// longer than the codes of every converter so they never decode to a link ID
+const DefaultRandomCodeLength = 16
+
+// Random returns a code of length characters of the alphabet read from crypto/rand, every
+// character is uniformly distributed
+func (a *Alphabet) Random(length int) (string, error) {
+	// bytes at or above limit would make the low characters more likely
+	limit := 256 - 256%a.base()
+	code := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(code) < length {
+		if _, err := crand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(code) < length {
+				code = append(code, a.chars[int(b)%a.base()])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+func (a *Alphabet) base() int {
+	return len(a.chars)
+}
+
+// shuffle returns the alphabet with its characters in an order derived from key
+func (a *Alphabet) shuffle(key string) *Alphabet {
+	hash := sha256.Sum256([]byte(key))
+	seed := int64(binary.BigEndian.Uint64(hash[:8]))
+	randSource := rand.NewSource(seed)
+	randGen := rand.New(randSource)
+
+	chars := []byte(a.chars)
+	randGen.Shuffle(len(chars), func(i, j int) {
+		chars[i], chars[j] = chars[j], chars[i]
+	})
+	return &Alphabet{name: a.name, chars: string(chars), fold: a.fold, maxLength: a.maxLength}
+}
+
+func (a *Alphabet) encode(num int64) string {
+	if num == 0 {
+		return string(a.chars[0])
+	}
+
+	base := int64(a.base())
+	var result []byte
+
+	for num > 0 {
+		remainder := num % base
+		num /= base
+		result = append([]byte{a.chars[remainder]}, result...)
+	}
+	return string(result)
+}
+
+// decode expects a normalized code
+func (a *Alphabet) decode(str string) (int64, error) {
+	if str == "" {
+		return 0, ErrEmptyCode
+	}
+	if len(str) > a.maxLength {
+		return 0, ErrCodeTooLong
+	}
+
+	base := int64(a.base())
+	var result int64
+	for _, c := range []byte(str) {
+		index := int64(strings.IndexByte(a.chars, c))
+		if index < 0 {
+			return 0, ErrInvalidChar
+		}
+		if result > (math.MaxInt64-index)/base {
+			return 0, ErrOutOfRange
+		}
+		result = result*base + index
+	}
+	return result, nil
+}
+
+func foldBase36(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func foldCrockford(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	switch c {
+	case 'O':
+		return '0'
+	case 'I', 'L':
+		return '1'
+	}
+	return c
+}
@@ -0,0 +1,124 @@
+package url_converter
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAlphabetCodecs(t *testing.T) {
+	for _, alphabet := range alphabets {
+		codec, err := NewPaddedCodec(alphabet, "shuffle-key", 15489079, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		padded, err := NewPaddedCodec(alphabet, "shuffle-key", 15489079, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		feistel, err := NewFeistelCodec(alphabet, "feistel-key", DefaultFeistelBits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converters := []Converter{codec, padded, feistel, NewChecked(alphabet, codec)}
+
+		for _, converter := range converters {
+			for _, id := range []int64{1, 42, 123456, 1<<40 - 1} {
+				shortCode := converter.Encode(id)
+				for _, c := range []byte(shortCode) {
+					if strings.IndexByte(alphabet.chars, c) < 0 {
+						t.Errorf("%v: expected only characters of the alphabet, got %v", alphabet, shortCode)
+					}
+				}
+				if decodedID, err := converter.Decode(shortCode); err != nil || decodedID != id {
+					t.Errorf("%v: expected %v, got %v %v", alphabet, id, decodedID, err)
+				}
+			}
+		}
+	}
+}
+
+func TestAlphabetNormalize(t *testing.T) {
+	tests := []struct {
+		alphabet *Alphabet
+		code     string
+		expected string
+	}{
+		{Crockford32, "abc", "ABC"},
+		{Crockford32, "oOiIlL", "001111"},
+		{Base36, "AbC", "abc"},
+		{Base57, "AbC", "AbC"},
+		{Base62, "AbC", "AbC"},
+	}
+
+	for _, test := range tests {
+		if normalized := test.alphabet.Normalize(test.code); normalized != test.expected {
+			t.Errorf("%v: expected %v for %v, got %v", test.alphabet, test.expected, test.code, normalized)
+		}
+	}
+}
+
+func TestAlphabetDecodeLookalikes(t *testing.T) {
+	codec, err := NewPaddedCodec(Crockford32, "shuffle-key", 15489079, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := int64(1); id < 2000; id++ {
+		shortCode := codec.Encode(id)
+		typed := strings.NewReplacer("0", "o", "1", "l").Replace(strings.ToLower(shortCode))
+		if decodedID, err := codec.Decode(typed); err != nil || decodedID != id {
+			t.Fatalf("expected %v for %v typed as %v, got %v %v", id, shortCode, typed, decodedID, err)
+		}
+	}
+
+	// the lookalikes are not part of base57 at all
+	base57, err := NewPaddedCodec(Base57, "shuffle-key", 15489079, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shortCode := range []string{"abc0", "abcO", "abc1", "abcI", "abcl"} {
+		if _, err := base57.Decode(shortCode); !errors.Is(err, ErrInvalidChar) {
+			t.Errorf("expected %v for %v, got %v", ErrInvalidChar, shortCode, err)
+		}
+	}
+}
+
+func TestAlphabetByName(t *testing.T) {
+	for name, expected := range map[string]*Alphabet{"": Base62, "base62": Base62, "crockford32": Crockford32, "base36": Base36, "base57": Base57} {
+		if alphabet, err := AlphabetByName(name); err != nil || alphabet != expected {
+			t.Errorf("%q: expected %v, got %v %v", name, expected, alphabet, err)
+		}
+	}
+	if _, err := AlphabetByName("base64"); err == nil {
+		t.Errorf("expected an error for an unknown alphabet")
+	}
+
+	// every alphabet has as many distinct characters as its name says
+	for _, alphabet := range alphabets {
+		seen := map[rune]bool{}
+		for _, c := range alphabet.chars {
+			seen[c] = true
+		}
+		if !strings.HasSuffix(alphabet.name, strconv.Itoa(len(seen))) || len(seen) != alphabet.base() {
+			t.Errorf("%v: unexpected characters %v", alphabet, alphabet.chars)
+		}
+	}
+}
+
+func TestAlphabetRandom(t *testing.T) {
+	for _, alphabet := range alphabets {
+		seen := map[string]bool{}
+		for i := 0; i < 100; i++ {
+			code, err := alphabet.Random(DefaultRandomCodeLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(code) != DefaultRandomCodeLength || strings.Trim(code, alphabet.chars) != "" || seen[code] {
+				t.Fatalf("%v: unexpected random code %v", alphabet, code)
+			}
+			seen[code] = true
+		}
+	}
+}
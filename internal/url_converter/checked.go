@@ -0,0 +1,154 @@
+package url_converter
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrCheckCharMismatch is returned for a code whose check character does not match, usually a typo
+var ErrCheckCharMismatch = errors.New("short code check character does not match")
+
+// Checked is a Converter that appends a Luhn mod N check character to the codes of another
+// converter. Decode rejects every code with one mistyped character and most codes with two
+// swapped neighbouring characters without decoding them.
+type Checked struct {
+	inner    Converter
+	alphabet *Alphabet
+}
+
+// NewChecked creates a Checked for a converter of the codes of an alphabet
+func NewChecked(alphabet *Alphabet, inner Converter) *Checked {
+	return &Checked{inner: inner, alphabet: alphabet}
+}
+
+// Encode returns an empty code if the inner converter cannot encode id, a lone check character
+// would be a valid looking code of another link
+func (c *Checked) Encode(id int64) string {
+	code := c.inner.Encode(id)
+	if code == "" {
+		return ""
+	}
+	return c.withCheckChar(code)
+}
+
+func (c *Checked) Decode(shortCode string) (int64, error) {
+	shortCode = c.alphabet.Normalize(shortCode)
+	if shortCode == "" {
+		return 0, ErrEmptyCode
+	}
+	if len(shortCode) < 2 {
+		return 0, ErrCodeTooShort
+	}
+
+	code, check := shortCode[:len(shortCode)-1], shortCode[len(shortCode)-1]
+	expected, err := c.checkChar(code)
+	if err != nil {
+		return 0, err
+	}
+	if strings.IndexByte(c.alphabet.chars, check) < 0 {
+		return 0, ErrInvalidChar
+	}
+	if check != expected {
+		return 0, ErrCheckCharMismatch
+	}
+	return c.inner.Decode(code)
+}
+
+// Canonical returns the canonical code of the inner converter with its check character
+func (c *Checked) Canonical(shortCode string) (string, error) {
+	if _, err := c.Decode(shortCode); err != nil {
+		return "", err
+	}
+	shortCode = c.alphabet.Normalize(shortCode)
+	code, err := Canonical(c.inner, shortCode[:len(shortCode)-1])
+	if err != nil {
+		return "", err
+	}
+	return c.withCheckChar(code), nil
+}
+
+// MaxID is the largest ID of the inner converter
+func (c *Checked) MaxID() int64 {
+	return MaxID(c.inner)
+}
+
+// Codes returns the codes of the inner converter with their check characters
+func (c *Checked) Codes(id int64) []string {
+	var codes []string
+	for _, code := range AllCodes(c.inner, id) {
+		if code != "" {
+			codes = append(codes, c.withCheckChar(code))
+		}
+	}
+	return codes
+}
+
+// Random returns a random code of length characters that passes the check character
+func (c *Checked) Random(length int) (string, error) {
+	code, err := c.alphabet.Random(length - 1)
+	if err != nil {
+		return "", err
+	}
+	return c.withCheckChar(code), nil
+}
+
+// Suggest returns the codes that differ from shortCode by one character or by two swapped
+// neighbouring characters and decode, the likely intended codes of a mistyped one
+func (c *Checked) Suggest(shortCode string) []string {
+	var suggestions []string
+	seen := map[string]bool{shortCode: true}
+	try := func(candidate []byte) {
+		if seen[string(candidate)] {
+			return
+		}
+		seen[string(candidate)] = true
+		if _, err := c.Decode(string(candidate)); err == nil {
+			suggestions = append(suggestions, string(candidate))
+		}
+	}
+
+	candidate := []byte(c.alphabet.Normalize(shortCode))
+	for i := range candidate {
+		original := candidate[i]
+		for j := 0; j < c.alphabet.base(); j++ {
+			candidate[i] = c.alphabet.chars[j]
+			try(candidate)
+		}
+		candidate[i] = original
+	}
+
+	for i := 0; i+1 < len(candidate); i++ {
+		candidate[i], candidate[i+1] = candidate[i+1], candidate[i]
+		try(candidate)
+		candidate[i], candidate[i+1] = candidate[i+1], candidate[i]
+	}
+	return suggestions
+}
+
+func (c *Checked) withCheckChar(code string) string {
+	check, err := c.checkChar(code)
+	if err != nil {
+		// the inner converter encodes to characters of the same alphabet
+		panic("url_converter: " + err.Error())
+	}
+	return code + string(check)
+}
+
+// checkChar is the Luhn mod N check character of code, with the characters valued by their
+// position in the unshuffled alphabet so it works for the codes of every converter
+func (c *Checked) checkChar(code string) (byte, error) {
+	base := c.alphabet.base()
+
+	sum := 0
+	factor := 2
+	for i := len(code) - 1; i >= 0; i-- {
+		value := strings.IndexByte(c.alphabet.chars, code[i])
+		if value < 0 {
+			return 0, ErrInvalidChar
+		}
+		addend := factor * value
+		sum += addend/base + addend%base
+		factor = 3 - factor
+	}
+	return c.alphabet.chars[(base-sum%base)%base], nil
+}
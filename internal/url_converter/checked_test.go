@@ -0,0 +1,98 @@
+package url_converter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCheckedRoundTrip(t *testing.T) {
+	codec := NewChecked(Base62, NewCodec("shuffle-key", 15489079))
+	for _, id := range []int64{1, 42, 123456, 1 << 40} {
+		shortCode := codec.Encode(id)
+		if decodedID, err := codec.Decode(shortCode); err != nil || decodedID != id {
+			t.Errorf("expected %v, got %v %v", id, decodedID, err)
+		}
+	}
+}
+
+func TestCheckedRejectsTypos(t *testing.T) {
+	codec := NewChecked(Base62, NewCodec("shuffle-key", 15489079))
+	shortCode := codec.Encode(123456)
+
+	// every single mistyped character is caught
+	for i := range shortCode {
+		for j := 0; j < len(base62Chars); j++ {
+			if base62Chars[j] == shortCode[i] {
+				continue
+			}
+			typo := shortCode[:i] + string(base62Chars[j]) + shortCode[i+1:]
+			if _, err := codec.Decode(typo); !errors.Is(err, ErrCheckCharMismatch) {
+				t.Fatalf("expected %v for %v, got %v", ErrCheckCharMismatch, typo, err)
+			}
+			if !slices.Contains(codec.Suggest(typo), shortCode) {
+				t.Fatalf("expected %v among the suggestions for %v, got %v", shortCode, typo, codec.Suggest(typo))
+			}
+		}
+	}
+
+	for _, shortCode := range []string{"", "a", "ab$"} {
+		if _, err := codec.Decode(shortCode); err == nil || errors.Is(err, ErrCheckCharMismatch) {
+			t.Errorf("expected a malformed code error for %q, got %v", shortCode, err)
+		}
+	}
+}
+
+func TestCheckedSuggestsTranspositions(t *testing.T) {
+	codec := NewChecked(Base62, NewCodec("shuffle-key", 15489079))
+	shortCode := codec.Encode(987654321)
+
+	for i := 0; i+1 < len(shortCode); i++ {
+		swapped := []byte(shortCode)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		if string(swapped) == shortCode {
+			continue
+		}
+		if _, err := codec.Decode(string(swapped)); err == nil {
+			continue // a swap Luhn mod N cannot detect
+		}
+		if !slices.Contains(codec.Suggest(string(swapped)), shortCode) {
+			t.Errorf("expected %v among the suggestions for %s", shortCode, swapped)
+		}
+	}
+}
+
+func TestCheckedKeyringCodes(t *testing.T) {
+	legacy := NewCodec("legacy-key", 15489079)
+	keyring, err := NewKeyring(Base62, 1, map[int]Converter{1: newVersionCodec(t, "first-key", 12345678)}, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewChecked(Base62, keyring)
+
+	codes := AllCodes(codec, 42)
+	if len(codes) != 2 || codes[0] != codec.Encode(42) {
+		t.Fatalf("expected the current and the legacy code, got %v", codes)
+	}
+	for _, shortCode := range codes {
+		if decodedID, err := codec.Decode(shortCode); err != nil || decodedID != 42 {
+			t.Errorf("expected 42 for %v, got %v %v", shortCode, decodedID, err)
+		}
+		if canonical, err := Canonical(codec, shortCode); err != nil || canonical != shortCode {
+			t.Errorf("expected %v as its own canonical code, got %v %v", shortCode, canonical, err)
+		}
+	}
+}
+
+func TestCheckedRandom(t *testing.T) {
+	codec := NewChecked(Base62, NewCodec("shuffle-key", 15489079))
+	code, err := codec.Random(DefaultRandomCodeLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a random code passes the check character and is too long to be the code of an ID
+	if _, err := codec.Decode(code); len(code) != DefaultRandomCodeLength || !errors.Is(err, ErrCodeTooLong) {
+		t.Errorf("expected a valid check character on %v, got %v", code, err)
+	}
+}
@@ -0,0 +1,135 @@
+package url_converter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// DefaultFeistelBits gives 7 character codes for up to about a trillion IDs
+const DefaultFeistelBits = 40
+
+// feistelRounds is the number of rounds FF1 uses as well
+const feistelRounds = 10
+
+// ErrCodeTooShort is returned by a fixed-width codec for codes below its width
+var ErrCodeTooShort = errors.New("short code is too short")
+
+// FeistelCodec is a Converter that encrypts IDs with a keyed Feistel network over a fixed number of bits,
+// so codes have a fixed width and neither reveal nor follow the order in which links were created.
+// Every round function is AES under a key derived from the secret, which makes the network a
+// pseudorandom permutation of the ID space.
+type FeistelCodec struct {
+	chars  *Alphabet
+	bits   int
+	width  int
+	mask   uint64
+	cipher cipher.Block
+}
+
+// NewFeistelCodec creates a codec for the codes of an alphabet for the IDs 1 to 2^idBits-1, idBits
+// must be even and between 16 and 62
+func NewFeistelCodec(alphabet *Alphabet, secret string, idBits int) (*FeistelCodec, error) {
+	if secret == "" {
+		return nil, errors.New("feistel codec needs a secret")
+	}
+	if idBits < 16 || idBits > 62 || idBits%2 != 0 {
+		return nil, fmt.Errorf("feistel codec bits must be even and between 16 and 62, got %d", idBits)
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeistelCodec{
+		chars:  alphabet.shuffle(secret),
+		bits:   idBits,
+		width:  len(alphabet.encode(1<<idBits - 1)),
+		mask:   1<<(idBits/2) - 1,
+		cipher: block,
+	}, nil
+}
+
+// Encode returns an empty code for an ID outside the range of the codec, see MaxID
+func (c *FeistelCodec) Encode(id int64) string {
+	if id <= 0 || id > c.MaxID() {
+		return ""
+	}
+
+	code := c.chars.encode(int64(c.encrypt(uint64(id))))
+	return strings.Repeat(c.chars.chars[:1], c.width-len(code)) + code
+}
+
+// MaxID is the largest ID that fits in the bits of the codec
+func (c *FeistelCodec) MaxID() int64 {
+	return 1<<c.bits - 1
+}
+
+// codeLengths are the shortest and longest codes Encode makes, both the width of the codec
+func (c *FeistelCodec) codeLengths() (int, int) {
+	return c.width, c.width
+}
+
+// Decode accepts only codes of the fixed width of the codec
+func (c *FeistelCodec) Decode(shortCode string) (int64, error) {
+	shortCode = c.chars.Normalize(shortCode)
+	if shortCode == "" {
+		return 0, ErrEmptyCode
+	}
+	if len(shortCode) > c.width {
+		return 0, ErrCodeTooLong
+	}
+	if len(shortCode) < c.width {
+		return 0, ErrCodeTooShort
+	}
+
+	value, err := c.chars.decode(shortCode)
+	if err != nil {
+		return 0, err
+	}
+	if bits.Len64(uint64(value)) > c.bits {
+		return 0, ErrOutOfRange
+	}
+
+	id := c.decrypt(uint64(value))
+	if id == 0 {
+		return 0, ErrOutOfRange
+	}
+	return int64(id), nil
+}
+
+func (c *FeistelCodec) encrypt(x uint64) uint64 {
+	half := c.bits / 2
+	left, right := x>>half, x&c.mask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^c.round(round, right)
+	}
+	return left<<half | right
+}
+
+func (c *FeistelCodec) decrypt(x uint64) uint64 {
+	half := c.bits / 2
+	left, right := x>>half, x&c.mask
+	for round := feistelRounds - 1; round >= 0; round-- {
+		left, right = right^c.round(round, left), left
+	}
+	return left<<half | right
+}
+
+// round is the round function, the width and round number are part of the block so every round
+// and every width is a different function
+func (c *FeistelCodec) round(round int, x uint64) uint64 {
+	var block [aes.BlockSize]byte
+	block[0] = byte(c.bits)
+	block[1] = byte(round)
+	binary.BigEndian.PutUint64(block[8:], x)
+	c.cipher.Encrypt(block[:], block[:])
+	return binary.BigEndian.Uint64(block[:8]) & c.mask
+}
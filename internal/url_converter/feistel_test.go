@@ -0,0 +1,137 @@
+package url_converter
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+func newTestFeistelCodec(t *testing.T, idBits int) *FeistelCodec {
+	t.Helper()
+
+	codec, err := NewFeistelCodec(Base62, "feistel-secret", idBits)
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	return codec
+}
+
+func TestFeistelRoundTripQuick(t *testing.T) {
+	for _, idBits := range []int{16, 32, DefaultFeistelBits, 62} {
+		codec := newTestFeistelCodec(t, idBits)
+		maxID := uint64(1)<<idBits - 1
+
+		roundTrip := func(x uint64) bool {
+			id := int64(x%maxID + 1)
+			shortCode := codec.Encode(id)
+			decodedID, err := codec.Decode(shortCode)
+			return err == nil && decodedID == id && len(shortCode) == codec.width
+		}
+		if err := quick.Check(roundTrip, &quick.Config{MaxCount: 20000}); err != nil {
+			t.Errorf("%v bits: %v", idBits, err)
+		}
+
+		// the edges of the range
+		for _, id := range []int64{1, 2, int64(maxID) - 1, int64(maxID)} {
+			if !roundTrip(uint64(id - 1)) {
+				t.Errorf("%v bits: failed to round trip %v", idBits, id)
+			}
+		}
+	}
+}
+
+func TestFeistelIsPermutation(t *testing.T) {
+	codec := newTestFeistelCodec(t, 16)
+
+	// every ID of the smallest width maps to a distinct code
+	seen := map[string]int64{}
+	for id := int64(1); id < 1<<16; id++ {
+		shortCode := codec.Encode(id)
+		if previous, exists := seen[shortCode]; exists {
+			t.Fatalf("ids %v and %v both encode to %v", previous, id, shortCode)
+		}
+		seen[shortCode] = id
+	}
+}
+
+func TestFeistelDecodeQuick(t *testing.T) {
+	codec := newTestFeistelCodec(t, DefaultFeistelBits)
+
+	// any code Decode accepts encodes back to itself
+	decodeEncode := func(raw [7]byte) bool {
+		code := make([]byte, len(raw))
+		for i, b := range raw {
+			code[i] = base62Chars[int(b)%len(base62Chars)]
+		}
+		id, err := codec.Decode(string(code))
+		return err != nil || codec.Encode(id) == string(code)
+	}
+	if err := quick.Check(decodeEncode, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFeistelCodes(t *testing.T) {
+	codec := newTestFeistelCodec(t, DefaultFeistelBits)
+
+	// consecutive IDs do not give consecutive or similar codes
+	first, second := codec.Encode(1), codec.Encode(2)
+	if first[:4] == second[:4] {
+		t.Errorf("expected unrelated codes, got %v and %v", first, second)
+	}
+
+	other, err := NewFeistelCodec(Base62, "other-secret", DefaultFeistelBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Encode(1) == first {
+		t.Error("expected different secrets to give different codes")
+	}
+
+	tests := []struct {
+		code     string
+		expected error
+	}{
+		{"", ErrEmptyCode},
+		{first[1:], ErrCodeTooShort},
+		{first + "a", ErrCodeTooLong},
+		{first[:6] + "-", ErrInvalidChar},
+		{"zzzzzzz", ErrOutOfRange},
+	}
+	for _, test := range tests {
+		if _, err := codec.Decode(test.code); err != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.code, test.expected, err)
+		}
+	}
+}
+
+func TestNewFeistelCodecInvalid(t *testing.T) {
+	for _, idBits := range []int{0, 14, 33, 64} {
+		if _, err := NewFeistelCodec(Base62, "feistel-secret", idBits); err == nil {
+			t.Errorf("expected an error for %v bits", idBits)
+		}
+	}
+	if _, err := NewFeistelCodec(Base62, "", DefaultFeistelBits); err == nil {
+		t.Error("expected an error without a secret")
+	}
+
+	codec := newTestFeistelCodec(t, 16)
+	if codec.MaxID() != 1<<16-1 || MaxID(codec) != codec.MaxID() {
+		t.Errorf("expected max ID %v received %v", 1<<16-1, codec.MaxID())
+	}
+	keyring, err := NewKeyring(Base62, 1, map[int]Converter{1: codec}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the wrappers must not turn the missing code into a short valid looking one
+	for _, converter := range []Converter{codec, NewChecked(Base62, codec), keyring, NewChecked(Base62, keyring)} {
+		for _, id := range []int64{0, 1 << 16, math.MaxInt64} {
+			if code := converter.Encode(id); code != "" {
+				t.Errorf("%T: expected no code for ID %v out of range received %q", converter, id, code)
+			}
+			if codes := AllCodes(converter, id); len(codes) != 0 {
+				t.Errorf("%T: expected no codes for ID %v out of range received %q", converter, id, codes)
+			}
+		}
+	}
+}
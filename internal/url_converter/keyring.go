@@ -0,0 +1,129 @@
+package url_converter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrUnknownKeyVersion is returned for a code whose first character is no key version of the keyring
+var ErrUnknownKeyVersion = errors.New("short code has an unknown key version")
+
+// Keyring is a Converter for key rotation. New codes are made with the current key and start with
+// its version character, and a code is decoded with the key of the version it starts with,
+// so links made with an older key keep working.
+//
+// Codes made before the keyring have no version character and are decoded with the legacy
+// converter. Any code could start with a version character, so versioned codes have to be longer
+// than every legacy code and the length alone tells the two apart.
+type Keyring struct {
+	alphabet *Alphabet
+	current  byte
+	versions map[byte]Converter
+	legacy   Converter
+	// legacyLength is the length of the longest legacy code
+	legacyLength int
+}
+
+// NewKeyring creates a keyring from the converters of every key version, legacy may be nil. The
+// version characters are those of the alphabet, which has to be the alphabet of the converters.
+// With a legacy converter every version has to make codes longer than the legacy ones.
+func NewKeyring(alphabet *Alphabet, current int, versions map[int]Converter, legacy Converter) (*Keyring, error) {
+	keyring := &Keyring{alphabet: alphabet, versions: map[byte]Converter{}, legacy: legacy}
+	if legacy != nil {
+		_, keyring.legacyLength = codeLengths(legacy)
+	}
+	for version, converter := range versions {
+		if version < 0 || version >= alphabet.base() {
+			return nil, fmt.Errorf("key version %d is not between 0 and %d", version, alphabet.base()-1)
+		}
+		if shortest, _ := codeLengths(converter); legacy != nil && 1+shortest <= keyring.legacyLength {
+			return nil, fmt.Errorf("codes of key version %d can be mistaken for legacy codes, they have to be longer than %d characters", version, keyring.legacyLength)
+		}
+		keyring.versions[alphabet.chars[version]] = converter
+	}
+
+	if _, exists := versions[current]; !exists {
+		return nil, fmt.Errorf("current key version %d is not in the keyring", current)
+	}
+	keyring.current = alphabet.chars[current]
+
+	return keyring, nil
+}
+
+// Encode returns an empty code if the current key version cannot encode id
+func (k *Keyring) Encode(id int64) string {
+	code := k.versions[k.current].Encode(id)
+	if code == "" {
+		return ""
+	}
+	return string(k.current) + code
+}
+
+// MaxID is the largest ID of the current key version
+func (k *Keyring) MaxID() int64 {
+	return MaxID(k.versions[k.current])
+}
+
+// Codes returns the code of id under every key version and the legacy converter that can encode it,
+// the current one first
+func (k *Keyring) Codes(id int64) []string {
+	var codes []string
+	if code := k.Encode(id); code != "" {
+		codes = append(codes, code)
+	}
+	for version, converter := range k.versions {
+		if version != k.current && id <= MaxID(converter) {
+			codes = append(codes, string(version)+converter.Encode(id))
+		}
+	}
+	if k.legacy != nil && id <= MaxID(k.legacy) {
+		codes = append(codes, k.legacy.Encode(id))
+	}
+	return codes
+}
+
+// Canonical returns the code of shortCode under the key version it starts with, or the legacy code
+func (k *Keyring) Canonical(shortCode string) (string, error) {
+	if _, err := k.Decode(shortCode); err != nil {
+		return "", err
+	}
+	if k.legacy != nil && len(shortCode) <= k.legacyLength {
+		return Canonical(k.legacy, shortCode)
+	}
+
+	version := k.alphabet.Normalize(shortCode[:1])[0]
+	code, err := Canonical(k.versions[version], shortCode[1:])
+	if err != nil {
+		return "", err
+	}
+	return string(version) + code, nil
+}
+
+func (k *Keyring) Decode(shortCode string) (int64, error) {
+	if shortCode == "" {
+		return 0, ErrEmptyCode
+	}
+
+	if k.legacy != nil && len(shortCode) <= k.legacyLength {
+		return k.legacy.Decode(shortCode)
+	}
+
+	if converter, exists := k.versions[k.alphabet.Normalize(shortCode[:1])[0]]; exists {
+		return converter.Decode(shortCode[1:])
+	}
+	if !strings.Contains(k.alphabet.chars, k.alphabet.Normalize(shortCode[:1])) {
+		return 0, ErrInvalidChar
+	}
+	return 0, ErrUnknownKeyVersion
+}
+
+// codeLengths are the shortest and longest codes of a converter, any length for converters
+// without a codeLengths method
+func codeLengths(converter Converter) (int, int) {
+	if lengths, ok := converter.(interface{ codeLengths() (int, int) }); ok {
+		return lengths.codeLengths()
+	}
+	return 1, math.MaxInt
+}
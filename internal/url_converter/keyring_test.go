@@ -0,0 +1,108 @@
+package url_converter
+
+import (
+	"testing"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	legacy := NewCodec("legacy-key", 15489079)
+	first := newVersionCodec(t, "first-key", 12345678)
+	second := newVersionCodec(t, "second-key", 87654321)
+
+	before, err := NewKeyring(Base62, 1, map[int]Converter{1: first}, legacy)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	after, err := NewKeyring(Base62, 2, map[int]Converter{1: first, 2: second}, legacy)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	oldCode := before.Encode(42)
+	newCode := after.Encode(42)
+	if oldCode[0] != '1' || newCode[0] != '2' || oldCode == newCode {
+		t.Errorf("expected codes with the key version, got %v and %v", oldCode, newCode)
+	}
+
+	// codes of every version and of the legacy key keep resolving after the rotation
+	codes := after.Codes(42)
+	if len(codes) != 3 || codes[0] != newCode {
+		t.Errorf("expected the current code and two older ones, got %v", codes)
+	}
+	for _, shortCode := range codes {
+		if id, err := after.Decode(shortCode); err != nil || id != 42 {
+			t.Errorf("%v: expected %v, got %v %v", shortCode, 42, id, err)
+		}
+		// every code stays the code of its own key
+		if canonical, err := Canonical(after, shortCode); err != nil || canonical != shortCode {
+			t.Errorf("%v: expected the same canonical code, got %v %v", shortCode, canonical, err)
+		}
+	}
+}
+
+func TestKeyringLegacyCodes(t *testing.T) {
+	legacy := NewCodec("your_key", 12345678)
+	keyring, err := NewKeyring(Base62, 2, map[int]Converter{
+		1: newVersionCodec(t, "first_rotation", 87654321),
+		2: newVersionCodec(t, "second_rotation", 11223344),
+	}, legacy)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	// plenty of legacy codes start with a version character
+	for id := int64(1); id < 100000; id++ {
+		if decoded, err := keyring.Decode(legacy.Encode(id)); err != nil || decoded != id {
+			t.Fatalf("%v: expected %v, got %v %v", legacy.Encode(id), id, decoded, err)
+		}
+		if decoded, err := keyring.Decode(keyring.Encode(id)); err != nil || decoded != id {
+			t.Fatalf("%v: expected %v, got %v %v", keyring.Encode(id), id, decoded, err)
+		}
+	}
+}
+
+func TestKeyringWithoutLegacy(t *testing.T) {
+	keyring, err := NewKeyring(Base62, 3, map[int]Converter{3: NewCodec("key", 12345678)}, nil)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	tests := []struct {
+		code     string
+		expected error
+	}{
+		{"", ErrEmptyCode},
+		{"4" + keyring.Encode(7)[1:], ErrUnknownKeyVersion},
+		{"-abc", ErrInvalidChar},
+		{"3a-c", ErrInvalidChar},
+	}
+	for _, test := range tests {
+		if _, err := keyring.Decode(test.code); err != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.code, test.expected, err)
+		}
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	codec := NewCodec("key", 12345678)
+
+	if _, err := NewKeyring(Base62, 1, map[int]Converter{2: codec}, nil); err == nil {
+		t.Error("expected an error for a missing current version")
+	}
+	// every version is one character of the alphabet
+	if _, err := NewKeyring(Base62, Base62.base(), map[int]Converter{Base62.base(): codec}, nil); err == nil {
+		t.Error("expected an error for a version out of range")
+	}
+	if _, err := NewKeyring(Base62, 1, map[int]Converter{1: codec}, NewCodec("legacy-key", 15489079)); err == nil {
+		t.Error("expected an error for versioned codes as short as legacy codes")
+	}
+}
+
+// newVersionCodec pads the codes of a key version beyond the longest legacy code
+func newVersionCodec(t *testing.T, shuffleKey string, xorSecretKey int64) *Codec {
+	codec, err := NewPaddedCodec(Base62, shuffleKey, xorSecretKey, Base62.MaxLength())
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	return codec
+}
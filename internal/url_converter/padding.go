@@ -0,0 +1,75 @@
+package url_converter
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+// padding maps the values of codes shorter than the minimum length onto codes of exactly that
+// length. A padded code is the zero character of the alphabet followed by an affine permutation
+// of the value over the remaining digits, so consecutive IDs do not get neighbouring codes.
+// Unpadded codes never start with the zero character, which keeps both kinds apart.
+type padding struct {
+	length  int
+	modulus uint64
+	mul     uint64
+	inverse uint64
+	add     uint64
+}
+
+func newPadding(chars *Alphabet, length int) (*padding, error) {
+	if length < 2 || length > chars.maxLength {
+		return nil, fmt.Errorf("minimum code length must be between 2 and %d, got %d", chars.maxLength, length)
+	}
+
+	modulus := uint64(1)
+	for i := 1; i < length; i++ {
+		modulus *= uint64(chars.base())
+	}
+
+	// the multiplier has to be coprime to the modulus to be invertible
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", chars.chars, length)))
+	mul := binary.BigEndian.Uint64(hash[:8])%modulus | 1
+	inverse := new(big.Int)
+	for mul >= modulus || inverse.ModInverse(new(big.Int).SetUint64(mul), new(big.Int).SetUint64(modulus)) == nil {
+		mul = (mul + 2) % modulus
+	}
+
+	return &padding{
+		length:  length,
+		modulus: modulus,
+		mul:     mul,
+		inverse: inverse.Uint64(),
+		add:     binary.BigEndian.Uint64(hash[8:16]) % modulus,
+	}, nil
+}
+
+// pad encodes a value whose unpadded code is shorter than the minimum length
+func (p *padding) pad(chars *Alphabet, value int64) string {
+	permuted := (p.mulMod(uint64(value), p.mul) + p.add) % p.modulus
+	digits := chars.encode(int64(permuted))
+	return strings.Repeat(chars.chars[:1], p.length-len(digits)) + digits
+}
+
+// unpad returns the value of a padded code, ok is false for codes that are not padded
+func (p *padding) unpad(chars *Alphabet, shortCode string) (value int64, ok bool, err error) {
+	if len(shortCode) != p.length || shortCode[0] != chars.chars[0] {
+		return 0, false, nil
+	}
+
+	digits, err := chars.decode(shortCode[1:])
+	if err != nil {
+		return 0, true, err
+	}
+	shifted := (uint64(digits) + p.modulus - p.add) % p.modulus
+	return int64(p.mulMod(shifted, p.inverse)), true, nil
+}
+
+func (p *padding) mulMod(x, y uint64) uint64 {
+	hi, lo := bits.Mul64(x, y)
+	return bits.Rem64(hi, lo, p.modulus)
+}
@@ -1,67 +1,142 @@
 package url_converter
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"encoding/binary"
-	"math/rand"
+	"errors"
+	"math"
 )
 
 const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 
-var shuffledChars string
+// The errors Decode returns for codes Encode can never produce
+var (
+	ErrEmptyCode   = errors.New("short code is empty")
+	ErrInvalidChar = errors.New("short code contains an invalid character")
+	ErrCodeTooLong = errors.New("short code is too long")
+	ErrOutOfRange  = errors.New("short code is out of range")
+)
 
-func InitBase62Array(shuffleKey string) {
-	shuffledChars = shuffleBase62Chars(shuffleKey)
+// Converter turns link IDs into short codes and back, Decode fails for any code Encode cannot produce
+type Converter interface {
+	Encode(id int64) string
+	Decode(shortCode string) (int64, error)
+}
+
+// Codec is the Converter of codes of XOR obfuscated IDs in a shuffled alphabet. It is immutable,
+// so codecs with different keys can be used concurrently in one process.
+type Codec struct {
+	chars   *Alphabet
+	xorKey  int64
+	padding *padding
 }
 
-func shuffleBase62Chars(key string) string {
-	hash := sha256.Sum256([]byte(key))
-	seed := int64(binary.BigEndian.Uint64(hash[:8]))
-	randSource := rand.NewSource(seed)
-	randGen := rand.New(randSource)
-
-	chars := []byte(base62Chars)
-	randGen.Shuffle(len(chars), func(i, j int) {
-		chars[i], chars[j] = chars[j], chars[i]
-	})
-	return string(chars)
+func NewCodec(shuffleKey string, xorSecretKey int64) *Codec {
+	return &Codec{chars: Base62.shuffle(shuffleKey), xorKey: xorSecretKey}
 }
 
-func base62Encode(num int64) string {
-	if num == 0 {
-		return string(shuffledChars[0])
+// NewPaddedCodec creates a codec for the codes of an alphabet that are at least minLength characters
+// long, a minLength of the length of the largest ID gives every ID a code of the same length and 0
+// turns padding off. Codes shorter than minLength made without padding still decode, so padding can
+// be turned on for an existing store.
+func NewPaddedCodec(alphabet *Alphabet, shuffleKey string, xorSecretKey int64, minLength int) (*Codec, error) {
+	codec := &Codec{chars: alphabet.shuffle(shuffleKey), xorKey: xorSecretKey}
+	if minLength != 0 {
+		padding, err := newPadding(codec.chars, minLength)
+		if err != nil {
+			return nil, err
+		}
+		codec.padding = padding
 	}
+	return codec, nil
+}
 
-	base := int64(62)
-	var result []byte
+func (c *Codec) Encode(id int64) string {
+	code := c.chars.encode(id ^ c.xorKey)
+	if c.padding != nil && len(code) < c.padding.length {
+		return c.padding.pad(c.chars, id^c.xorKey)
+	}
+	return code
+}
 
-	for num > 0 {
-		remainder := num % base
-		num /= base
-		result = append([]byte{shuffledChars[remainder]}, result...)
+// codeLengths are the shortest and longest codes Encode makes
+func (c *Codec) codeLengths() (int, int) {
+	if c.padding != nil {
+		return c.padding.length, c.chars.maxLength
 	}
-	return string(result)
+	return 1, c.chars.maxLength
 }
 
-func base62Decode(str string) int64 {
-	base := int64(62)
-	var result int64
-	for _, c := range []byte(str) {
-		index := int64(bytes.IndexByte([]byte(shuffledChars), c))
-		result = result*base + index
+// Decode returns the ID of a code produced by Encode, any other input is an error
+// and never maps to an arbitrary ID
+func (c *Codec) Decode(shortCode string) (int64, error) {
+	shortCode = c.chars.Normalize(shortCode)
+
+	var obfuscatedID int64
+	var padded bool
+	var err error
+	if c.padding != nil {
+		obfuscatedID, padded, err = c.padding.unpad(c.chars, shortCode)
+	}
+	if !padded {
+		obfuscatedID, err = c.chars.decode(shortCode)
 	}
-	return result
+	if err != nil {
+		return 0, err
+	}
+
+	originalID := obfuscatedID ^ c.xorKey
+	if originalID <= 0 {
+		return 0, ErrOutOfRange
+	}
+	return originalID, nil
 }
 
+// shuffledChars backs the package functions, which predate Codec
+var shuffledChars *Alphabet
+
+func InitBase62Array(shuffleKey string) {
+	shuffledChars = Base62.shuffle(shuffleKey)
+}
+
+// EncodeID is Codec.Encode with the alphabet set by InitBase62Array
 func EncodeID(id int64, xorSecretKey int64) string {
-	obfuscatedID := id ^ xorSecretKey
-	shortCode := base62Encode(obfuscatedID)
-	return shortCode
+	return (&Codec{chars: shuffledChars, xorKey: xorSecretKey}).Encode(id)
+}
+
+// DecodeShortCode is Codec.Decode with the alphabet set by InitBase62Array
+func DecodeShortCode(shortCode string, xorSecretKey int64) (int64, error) {
+	return (&Codec{chars: shuffledChars, xorKey: xorSecretKey}).Decode(shortCode)
+}
+
+// MaxID is the largest ID a converter can encode, converters without a MaxID method encode every ID
+func MaxID(converter Converter) int64 {
+	if limited, ok := converter.(interface{ MaxID() int64 }); ok {
+		return limited.MaxID()
+	}
+	return math.MaxInt64
 }
 
-func DecodeShortCode(shortCode string, xorSecretKey int64) int64 {
-	obfuscatedID := base62Decode(shortCode)
-	originalID := obfuscatedID ^ xorSecretKey
-	return originalID
+// AllCodes returns every code that decodes to id, which is more than the code Encode returns
+// for converters that still decode the codes of older keys, and none for an ID it cannot encode
+func AllCodes(converter Converter, id int64) []string {
+	if multi, ok := converter.(interface{ Codes(id int64) []string }); ok {
+		return multi.Codes(id)
+	}
+	if code := converter.Encode(id); code != "" {
+		return []string{code}
+	}
+	return nil
+}
+
+// Canonical returns the code of AllCodes that shortCode is a spelling of, like a lowercase or an
+// unpadded one. With key rotation that is the code of the key shortCode was made with, which is
+// not the code Encode gives.
+func Canonical(converter Converter, shortCode string) (string, error) {
+	if canonical, ok := converter.(interface{ Canonical(string) (string, error) }); ok {
+		return canonical.Canonical(shortCode)
+	}
+	id, err := converter.Decode(shortCode)
+	if err != nil {
+		return "", err
+	}
+	return converter.Encode(id), nil
 }
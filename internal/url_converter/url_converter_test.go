@@ -1,28 +1,29 @@
 package url_converter
 
 import (
-	"fmt"
+	"math"
 	"testing"
 )
 
 func TestEncodeDecodeBase62(t *testing.T) {
-	InitBase62Array("shuffle-key")
+	chars := Base62.shuffle("shuffle-key")
 	id := int64(123456)
 	expectedShortCode := "pQn"
-	shortCode := base62Encode(id)
+	shortCode := chars.encode(id)
 
 	if shortCode != expectedShortCode {
 		t.Fatalf("expected %v, got %v", expectedShortCode, shortCode)
 	}
 
-	decodedID := base62Decode(shortCode)
+	decodedID, err := chars.decode(shortCode)
 
-	if decodedID != id {
+	if err != nil || decodedID != id {
 		t.Fatalf("expected %v, got %v", id, decodedID)
 	}
 }
 
 func TestEncodeDecodeID(t *testing.T) {
+	InitBase62Array("shuffle-key")
 	id := int64(1)
 	xorSecretKey := int64(15489079) // Large prime number
 	expectedShortCode := "oyAVB"
@@ -33,10 +34,114 @@ func TestEncodeDecodeID(t *testing.T) {
 		t.Fatalf("expected %v, got %v", expectedShortCode, shortCode)
 	}
 
-	decodedID := DecodeShortCode(shortCode, xorSecretKey)
+	decodedID, err := DecodeShortCode(shortCode, xorSecretKey)
 
-	if decodedID != id {
-		t.Fatalf("expected %v, got %v", id, decodedID)
+	if err != nil || decodedID != id {
+		t.Fatalf("expected %v, got %v %v", id, decodedID, err)
+	}
+}
+
+func TestDecodeShortCodeInvalid(t *testing.T) {
+	InitBase62Array("shuffle-key")
+	xorSecretKey := int64(15489079)
+
+	tests := []struct {
+		code     string
+		expected error
+	}{
+		{"", ErrEmptyCode},
+		{"spring-sale", ErrInvalidChar},
+		{"ab$c", ErrInvalidChar},
+		{"abc\x00", ErrInvalidChar},
+		{"zzzzzzzzzzzz", ErrCodeTooLong},
+		{"zzzzzzzzzzz", ErrOutOfRange},
+		// XOR with the key gives 0
+		{shuffledChars.encode(xorSecretKey), ErrOutOfRange},
+	}
+
+	for _, test := range tests {
+		if id, err := DecodeShortCode(test.code, xorSecretKey); err != test.expected {
+			t.Errorf("%q: expected %v, got %v %v", test.code, test.expected, id, err)
+		}
+	}
+
+	// the largest ID still round trips
+	id, err := DecodeShortCode(EncodeID(math.MaxInt64, xorSecretKey), xorSecretKey)
+	if err != nil || id != math.MaxInt64 {
+		t.Errorf("expected %v, got %v %v", int64(math.MaxInt64), id, err)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	InitBase62Array("shuffle-key")
+	codec := NewCodec("shuffle-key", 15489079)
+	other := NewCodec("other-key", 15489079)
+
+	for _, id := range []int64{1, 42, 123456, math.MaxInt64} {
+		shortCode := codec.Encode(id)
+		if shortCode != EncodeID(id, 15489079) {
+			t.Errorf("expected the codec to match EncodeID, got %v and %v", shortCode, EncodeID(id, 15489079))
+		}
+		if decodedID, err := codec.Decode(shortCode); err != nil || decodedID != id {
+			t.Errorf("expected %v, got %v %v", id, decodedID, err)
+		}
+		if other.Encode(id) == shortCode {
+			t.Errorf("expected different keys to give different codes for %v", id)
+		}
+	}
+}
+
+func TestPaddedCodec(t *testing.T) {
+	legacy := NewCodec("shuffle-key", 15489079)
+	codec, err := NewPaddedCodec(Base62, "shuffle-key", 15489079, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, id := range []int64{1, 2, 3, 42, 15489079, 15489080, 123456789, 1 << 40, math.MaxInt64} {
+		shortCode := codec.Encode(id)
+		if len(shortCode) < 7 {
+			t.Errorf("expected at least 7 characters for %v, got %v", id, shortCode)
+		}
+		if seen[shortCode] {
+			t.Errorf("expected a unique code for %v, got %v", id, shortCode)
+		}
+		seen[shortCode] = true
+
+		if decodedID, err := codec.Decode(shortCode); err != nil || decodedID != id {
+			t.Errorf("expected %v, got %v %v", id, decodedID, err)
+		}
+		// codes made before padding was turned on keep working
+		if decodedID, err := codec.Decode(legacy.Encode(id)); err != nil || decodedID != id {
+			t.Errorf("expected the legacy code of %v to decode, got %v %v", id, decodedID, err)
+		}
+	}
+
+	// consecutive IDs do not give neighbouring codes
+	if a, b := codec.Encode(100), codec.Encode(101); a[:5] == b[:5] {
+		t.Errorf("expected unrelated codes, got %v and %v", a, b)
+	}
+}
+
+func TestPaddedCodecFixedWidth(t *testing.T) {
+	codec, err := NewPaddedCodec(Base62, "shuffle-key", 15489079, Base62.maxLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id < 5000; id++ {
+		shortCode := codec.Encode(id)
+		if len(shortCode) != Base62.maxLength {
+			t.Fatalf("expected %v characters for %v, got %v", Base62.maxLength, id, shortCode)
+		}
+		if decodedID, err := codec.Decode(shortCode); err != nil || decodedID != id {
+			t.Fatalf("expected %v, got %v %v", id, decodedID, err)
+		}
+	}
+
+	for _, length := range []int{1, Base62.maxLength + 1} {
+		if _, err := NewPaddedCodec(Base62, "shuffle-key", 15489079, length); err == nil {
+			t.Errorf("expected an error for the minimum length %v", length)
+		}
 	}
-	fmt.Printf("Decoded ID: %d\n", decodedID)
 }
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+type arcItem struct {
+	entry
+	elem *list.Element
+	// the list the item or its ghost is in
+	list *list.List
+}
+
+// ARCCache is the Adaptive Replacement Cache of Megiddo and Modha. It splits the capacity between
+// items used once (t1) and items used again (t2), and remembers the keys recently evicted from each
+// (b1 and b2). A hit on a remembered key moves the split towards the list that lost it, so the
+// cache adapts between recency and frequency without tuning, and a scan only churns t1.
+type ARCCache struct {
+	// items in t1 and t2, ghosts in b1 and b2
+	items map[string]*arcItem
+	// most recently used at the front
+	t1, t2, b1, b2 *list.List
+	// target size of t1
+	target   int
+	capacity int
+	lock     sync.Mutex
+	expiry
+}
+
+func NewARCCache(capacity int, ttl time.Duration) Cache {
+	return &ARCCache{
+		items:    make(map[string]*arcItem, 2*capacity),
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		capacity: capacity,
+		expiry:   expiry{ttl: ttl, stop: make(chan struct{})},
+	}
+}
+
+// Set
+func (arc *ARCCache) Set(key string, value string) {
+	arc.SetWithTTL(key, value, arc.ttl)
+}
+
+// SetWithTTL
+func (arc *ARCCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := arc.deadline(ttl, arc.removeExpired)
+
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	item, exists := arc.items[key]
+	switch {
+	case exists && arc.cached(item):
+		arc.move(item, arc.t2)
+
+	case exists && item.list == arc.b1:
+		// evicted from t1 too early, give t1 more room
+		arc.target = min(arc.capacity, arc.target+max(1, arc.b2.Len()/arc.b1.Len()))
+		arc.replace(false)
+		arc.move(item, arc.t2)
+
+	case exists && item.list == arc.b2:
+		// evicted from t2 too early, give t2 more room
+		arc.target = max(0, arc.target-max(1, arc.b1.Len()/arc.b2.Len()))
+		arc.replace(true)
+		arc.move(item, arc.t2)
+
+	default:
+		if arc.t1.Len()+arc.b1.Len() >= arc.capacity {
+			if arc.t1.Len() < arc.capacity {
+				arc.forget(arc.b1)
+				arc.replace(false)
+			} else {
+				arc.remove(arc.t1.Back().Value.(*arcItem))
+			}
+		} else if total := arc.t1.Len() + arc.t2.Len() + arc.b1.Len() + arc.b2.Len(); total >= arc.capacity {
+			if total >= 2*arc.capacity {
+				arc.forget(arc.b2)
+			}
+			arc.replace(false)
+		}
+
+		item = &arcItem{entry: entry{key: key}}
+		item.list = arc.t1
+		item.elem = arc.t1.PushFront(item)
+		arc.items[key] = item
+	}
+
+	item.value = value
+	item.expiresAt = expiresAt
+}
+
+// Get
+func (arc *ARCCache) Get(key string) (string, error) {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	item, exists := arc.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	arc.move(item, arc.t2)
+	return item.value, nil
+}
+
+// Peek
+func (arc *ARCCache) Peek(key string) (string, error) {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	item, exists := arc.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	return item.value, nil
+}
+
+// Delete
+func (arc *ARCCache) Delete(key string) {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	if item, exists := arc.items[key]; exists && arc.cached(item) {
+		arc.remove(item)
+	}
+}
+
+// Len
+func (arc *ARCCache) Len() int {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	return arc.t1.Len() + arc.t2.Len()
+}
+
+// Purge
+func (arc *ARCCache) Purge() {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	arc.items = make(map[string]*arcItem, 2*arc.capacity)
+	arc.t1.Init()
+	arc.t2.Init()
+	arc.b1.Init()
+	arc.b2.Init()
+	arc.target = 0
+}
+
+// lookup finds an item that has not expired, the caller holds the lock
+func (arc *ARCCache) lookup(key string) (*arcItem, bool) {
+	item, exists := arc.items[key]
+	if !exists || !arc.cached(item) {
+		return nil, false
+	}
+
+	if item.expired(time.Now()) {
+		arc.remove(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+func (arc *ARCCache) cached(item *arcItem) bool {
+	return item.list == arc.t1 || item.list == arc.t2
+}
+
+// replace turns the least recently used item of t1 or t2 into a ghost when the cache is full
+func (arc *ARCCache) replace(inB2 bool) {
+	if arc.t1.Len()+arc.t2.Len() < arc.capacity {
+		// deleted or expired items left room
+		return
+	}
+
+	if arc.t1.Len() > 0 && (arc.t1.Len() > arc.target || (inB2 && arc.t1.Len() == arc.target) || arc.t2.Len() == 0) {
+		arc.evict(arc.t1.Back().Value.(*arcItem), arc.b1)
+	} else {
+		arc.evict(arc.t2.Back().Value.(*arcItem), arc.b2)
+	}
+}
+
+// evict keeps only the key of an item in a ghost list
+func (arc *ARCCache) evict(item *arcItem, ghosts *list.List) {
+	item.value = ""
+	arc.move(item, ghosts)
+}
+
+// forget drops the oldest ghost of a list
+func (arc *ARCCache) forget(ghosts *list.List) {
+	if oldest := ghosts.Back(); oldest != nil {
+		arc.remove(oldest.Value.(*arcItem))
+	}
+}
+
+func (arc *ARCCache) move(item *arcItem, to *list.List) {
+	item.list.Remove(item.elem)
+	item.list = to
+	item.elem = to.PushFront(item)
+}
+
+func (arc *ARCCache) remove(item *arcItem) {
+	item.list.Remove(item.elem)
+	delete(arc.items, item.key)
+}
+
+func (arc *ARCCache) removeExpired(now time.Time) {
+	arc.lock.Lock()
+	defer arc.lock.Unlock()
+
+	for _, item := range arc.items {
+		if arc.cached(item) && item.expired(now) {
+			arc.remove(item)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestARCScanKeepsFrequent(t *testing.T) {
+
+	arc := NewARCCache(4, 0)
+
+	arc.Set("a", "va")
+	arc.Get("a")
+	arc.Set("b", "vb")
+	arc.Get("b")
+
+	for i := 0; i < 100; i++ {
+		arc.Set("crawled_"+strconv.Itoa(i), "v")
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := arc.Get(key); err != nil {
+			t.Errorf("expected key %v to exist", key)
+		}
+	}
+	if arc.Len() != 4 {
+		t.Errorf("expected %v received %v", 4, arc.Len())
+	}
+}
+
+func TestARCGhostHitAdapts(t *testing.T) {
+
+	arc := NewARCCache(2, 0).(*ARCCache)
+
+	arc.Set("a", "va")
+	arc.Get("a")
+	arc.Set("b", "vb")
+	// c replaces b, which is remembered as evicted from t1
+	arc.Set("c", "vc")
+	if _, err := arc.Peek("b"); err == nil {
+		t.Fatal("expected key b to be evicted")
+	}
+
+	arc.Set("b", "vb2")
+	if arc.target != 1 {
+		t.Errorf("expected target %v received %v", 1, arc.target)
+	}
+	if val, err := arc.Peek("b"); err != nil || val != "vb2" {
+		t.Errorf("expected vb2 received %v, %v", val, err)
+	}
+	if arc.Len() != 2 {
+		t.Errorf("expected %v received %v", 2, arc.Len())
+	}
+}
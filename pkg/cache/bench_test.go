@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// trace is a replayable sequence of requested keys
+type trace []string
+
+// zipfTrace requests keys with a Zipfian distribution, a few popular links and a long tail
+func zipfTrace(seed int64, length int, keys uint64, skew float64) trace {
+	r := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(r, skew, 1, keys-1)
+
+	t := make(trace, length)
+	for i := range t {
+		t[i] = "link_" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return t
+}
+
+// withScans interleaves a crawler reading scanLength links nobody else asks for every period requests
+func withScans(t trace, period int, scanLength int) trace {
+	scanned := make(trace, 0, len(t)+len(t)/period*scanLength)
+	crawled := 0
+	for i, key := range t {
+		if i > 0 && i%period == 0 {
+			for j := 0; j < scanLength; j++ {
+				scanned = append(scanned, "crawled_"+strconv.Itoa(crawled))
+				crawled++
+			}
+		}
+		scanned = append(scanned, key)
+	}
+	return scanned
+}
+
+// replay looks every key up like RedirectURL does, setting it on a miss, and returns the hit ratio
+func replay(cache Cache, t trace) float64 {
+	hits := 0
+	for _, key := range t {
+		if _, err := cache.Get(key); err == nil {
+			hits++
+			continue
+		}
+		cache.Set(key, key)
+	}
+	return float64(hits) / float64(len(t))
+}
+
+type namedTrace struct {
+	name  string
+	trace trace
+}
+
+// traces are built on first use, so only the benchmarks that replay them pay for them
+var traces = sync.OnceValue(func() []namedTrace {
+	return []namedTrace{
+		{"zipf", zipfTrace(1, 200000, 100000, 1.1)},
+		{"zipf+scans", withScans(zipfTrace(1, 200000, 100000, 1.1), 5000, 2000)},
+	}
+})
+
+const traceCacheCapacity = 1000
+
+// BenchmarkHitRatio replays the traces against every policy, compare the hit% of the results
+func BenchmarkHitRatio(b *testing.B) {
+	for _, tr := range traces() {
+		for _, policy := range policies {
+			b.Run(tr.name+"/"+policy, func(b *testing.B) {
+				var ratio float64
+				for i := 0; i < b.N; i++ {
+					cache := newTestCache(b, policy, traceCacheCapacity, 0)
+					ratio = replay(cache, tr.trace)
+				}
+				b.ReportMetric(100*ratio, "hit%")
+			})
+		}
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	tr := withScans(zipfTrace(2, 50000, 20000, 1.1), 2000, 600)
+	lru := replay(newTestCache(t, PolicyLRU, 300, 0), tr)
+
+	for _, policy := range []string{Policy2Q, PolicyARC, PolicyTinyLFU} {
+		if ratio := replay(newTestCache(t, policy, 300, 0), tr); ratio <= lru {
+			t.Errorf("policy %v: expected a hit ratio above %.3f of lru received %.3f", policy, lru, ratio)
+		}
+	}
+}
+
+// BenchmarkParallel replays a Zipfian trace from every goroutine like concurrent redirects do, run it
+// with -cpu 1,2,4,8 to see how the throughput of each cache scales with GOMAXPROCS
+func BenchmarkParallel(b *testing.B) {
+	tr := zipfTrace(3, 1<<16, 100000, 1.1)
+	caches := []struct {
+		name   string
+		shards int
+	}{
+		{"lru", 0},
+		{"lru-sharded-16", 16},
+		{"lru-sharded-64", 64},
+	}
+
+	for _, c := range caches {
+		b.Run(c.name, func(b *testing.B) {
+			cache, err := NewCache(Options{Capacity: 10000, Shards: c.shards})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(cache.Stop)
+			replay(cache, tr)
+
+			var offset atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// every goroutine starts at another place of the trace
+				i := int(offset.Add(7919))
+				for pb.Next() {
+					key := tr[i%len(tr)]
+					if _, err := cache.Get(key); err != nil {
+						cache.Set(key, key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -1,15 +1,76 @@
 package cache
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 type Cache interface {
+	// Get returns the value and marks it as recently used
 	Get(string) (string, error)
+	// Peek returns the value without changing how recently it was used
+	Peek(string) (string, error)
+	// Set stores the value with the default TTL of the cache
 	Set(string, string)
+	// SetWithTTL stores the value until ttl has passed, a ttl of 0 keeps it until it is evicted
+	SetWithTTL(string, string, time.Duration)
+	Delete(string)
+	// Len is the number of stored entries, expired entries count until they are removed
+	Len() int
+	// Purge removes every entry
+	Purge()
+	// Stop ends the background expiry of the cache
+	Stop()
+}
+
+// eviction policies of NewCache
+const (
+	PolicyLRU     = "lru"
+	PolicyLFU     = "lfu"
+	Policy2Q      = "2q"
+	PolicyARC     = "arc"
+	PolicyTinyLFU = "tinylfu"
+)
+
+// Options configure NewCache
+type Options struct {
+	Capacity int
+	// entries expire after TTL, 0 keeps them until they are evicted
+	TTL time.Duration
+	// eviction policy, empty for PolicyLRU
+	Policy string
+	// number of independently locked shards the capacity is split over, 0 or 1 for a single lock
+	Shards int
 }
 
-func NewCache(capacity int) Cache {
-	if capacity < 1 {
-		panic("capacity should be more than 1")
+// NewCache creates a cache with the eviction policy of the options
+func NewCache(options Options) (Cache, error) {
+	if options.Capacity < 1 {
+		return nil, errors.New("capacity should be at least 1")
+	}
+	if options.Shards < 0 || options.Shards > options.Capacity {
+		return nil, fmt.Errorf("shards must be between 0 and the capacity %d, got %d", options.Capacity, options.Shards)
 	}
 
-	return NewLRUCache(capacity)
+	var newShard func(capacity int) Cache
+	switch options.Policy {
+	case "", PolicyLRU:
+		newShard = func(capacity int) Cache { return NewLRUCacheWithTTL(capacity, options.TTL) }
+	case PolicyLFU:
+		newShard = func(capacity int) Cache { return NewLFUCache(capacity, options.TTL) }
+	case Policy2Q:
+		newShard = func(capacity int) Cache { return NewTwoQueueCache(capacity, options.TTL) }
+	case PolicyARC:
+		newShard = func(capacity int) Cache { return NewARCCache(capacity, options.TTL) }
+	case PolicyTinyLFU:
+		newShard = func(capacity int) Cache { return NewTinyLFUCache(capacity, options.TTL) }
+	default:
+		return nil, fmt.Errorf("unknown cache policy %q", options.Policy)
+	}
 
+	if options.Shards > 1 {
+		return NewShardedCache(options.Capacity, options.Shards, newShard), nil
+	}
+	return newShard(options.Capacity), nil
 }
@@ -1,9 +1,28 @@
 package cache
 
-import "testing"
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var policies = []string{PolicyLRU, PolicyLFU, Policy2Q, PolicyARC, PolicyTinyLFU}
+
+func newTestCache(t testing.TB, policy string, capacity int, ttl time.Duration) Cache {
+	cache, err := NewCache(Options{Capacity: capacity, TTL: ttl, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cache.Stop)
+	return cache
+}
 
 func TestCacheSetAndGet(t *testing.T) {
-	cache := NewCache(3)
+	cache, err := NewCache(Options{Capacity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
 	key, value := "testKey", "testValue"
 	cache.Set(key, value)
 
@@ -11,3 +30,123 @@ func TestCacheSetAndGet(t *testing.T) {
 		t.Errorf(`Cache.Set("%s", "%s") = %s; want %s`, key, value, v, value)
 	}
 }
+
+func TestNewCacheOptions(t *testing.T) {
+	if _, err := NewCache(Options{Capacity: 0}); err == nil {
+		t.Error("expected an error for capacity 0")
+	}
+	if _, err := NewCache(Options{Capacity: 10, Policy: "mru"}); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+	if _, err := NewCache(Options{Capacity: 10, Shards: 11}); err == nil {
+		t.Error("expected an error for more shards than capacity")
+	}
+
+	expected := map[string]string{
+		"":            "*cache.LRUCache",
+		PolicyLRU:     "*cache.LRUCache",
+		PolicyLFU:     "*cache.LFUCache",
+		Policy2Q:      "*cache.TwoQueueCache",
+		PolicyARC:     "*cache.ARCCache",
+		PolicyTinyLFU: "*cache.TinyLFUCache",
+	}
+	for policy, want := range expected {
+		cache, err := NewCache(Options{Capacity: 10, Policy: policy})
+		if err != nil {
+			t.Errorf("policy %q: %v", policy, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", cache); got != want {
+			t.Errorf("policy %q: expected %v received %v", policy, want, got)
+		}
+	}
+
+	cache, err := NewCache(Options{Capacity: 10, Policy: PolicyARC, Shards: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded, ok := cache.(*ShardedCache)
+	if !ok {
+		t.Fatalf("expected %v received %T", "*cache.ShardedCache", cache)
+	}
+	for _, shard := range sharded.shards {
+		if got := fmt.Sprintf("%T", shard); got != "*cache.ARCCache" {
+			t.Errorf("expected shards of %v received %v", "*cache.ARCCache", got)
+		}
+	}
+}
+
+// TestCachePolicies checks what every policy guarantees, whichever items they evict
+func TestCachePolicies(t *testing.T) {
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			cache := newTestCache(t, policy, 3, 0)
+
+			cache.Set("a", "va")
+			cache.Set("a", "va2")
+			if val, err := cache.Get("a"); err != nil || val != "va2" {
+				t.Errorf("expected va2 received %v, %v", val, err)
+			}
+			if val, err := cache.Peek("a"); err != nil || val != "va2" {
+				t.Errorf("expected va2 received %v, %v", val, err)
+			}
+			if _, err := cache.Get("missing"); err == nil {
+				t.Error("expected missing key to be reported")
+			}
+			if _, err := cache.Peek("missing"); err == nil {
+				t.Error("expected missing key to be reported")
+			}
+
+			// never more items than the capacity, and the newest is kept or a known one is
+			for i := 0; i < 20; i++ {
+				key := "key_" + strconv.Itoa(i)
+				cache.Set(key, "value_"+strconv.Itoa(i))
+				cache.Get(key)
+				if cache.Len() > 3 {
+					t.Fatalf("expected at most %v items received %v", 3, cache.Len())
+				}
+			}
+
+			cache.Set("b", "vb")
+			cache.Delete("b")
+			cache.Delete("missing")
+			if _, err := cache.Get("b"); err == nil {
+				t.Error("expected deleted key to be gone")
+			}
+
+			cache.Purge()
+			if cache.Len() != 0 {
+				t.Errorf("expected %v received %v", 0, cache.Len())
+			}
+			for _, key := range []string{"c", "d", "e"} {
+				cache.Set(key, "v"+key)
+			}
+			if cache.Len() != 3 {
+				t.Errorf("expected %v received %v", 3, cache.Len())
+			}
+		})
+	}
+}
+
+func TestCachePoliciesExpiry(t *testing.T) {
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			cache := newTestCache(t, policy, 3, 20*time.Millisecond)
+
+			cache.Set("a", "va")
+			cache.SetWithTTL("b", "vb", time.Hour)
+
+			time.Sleep(30 * time.Millisecond)
+
+			if _, err := cache.Peek("a"); err == nil {
+				t.Error("expected key a to expire with the default TTL")
+			}
+			if _, err := cache.Get("b"); err != nil {
+				t.Error("expected key b to exist")
+			}
+			if cache.Len() != 1 {
+				t.Errorf("expected %v received %v", 1, cache.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// the janitor sweeps at least every maxJanitorInterval and at most every minJanitorInterval, a sweep
+// locks the whole cache and Get never returns expired items anyway
+const (
+	minJanitorInterval = time.Second
+	maxJanitorInterval = time.Minute
+)
+
+// expiry holds the default TTL and the janitor every policy shares
+type expiry struct {
+	// default TTL of Set, 0 means no expiry
+	ttl time.Duration
+	// the janitor only runs once an item with an expiry was set
+	janitor sync.Once
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// entry is a stored value of the policies built on container/list
+type entry struct {
+	key   string
+	value string
+	// zero if the entry never expires
+	expiresAt time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// deadline is when an item set now with ttl expires, starting the janitor that calls sweep if needed
+func (e *expiry) deadline(ttl time.Duration, sweep func(time.Time)) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	e.janitor.Do(func() { go e.runJanitor(janitorInterval(e.ttl, ttl), sweep) })
+	return time.Now().Add(ttl)
+}
+
+// Stop ends the janitor, the cache keeps working and expired items are still never returned
+func (e *expiry) Stop() {
+	e.stopped.Do(func() { close(e.stop) })
+}
+
+func (e *expiry) runJanitor(interval time.Duration, sweep func(time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case now := <-ticker.C:
+			sweep(now)
+		}
+	}
+}
+
+// janitorInterval sweeps about as often as items expire, going by the default TTL if there is one
+// and else by the first TTL, which may be that of one very short lived item
+func janitorInterval(defaultTTL time.Duration, ttl time.Duration) time.Duration {
+	if defaultTTL > 0 {
+		ttl = defaultTTL
+	}
+	return min(max(ttl, minJanitorInterval), maxJanitorInterval)
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+type lfuItem struct {
+	entry
+	freq int
+	elem *list.Element
+}
+
+// LFUCache evicts the least frequently used item, the least recently used one among equally used items.
+// A link that was popular once stays cached until others are used more often.
+type LFUCache struct {
+	store map[string]*lfuItem
+	// items of every use count, most recently used at the front
+	freqs    map[int]*list.List
+	minFreq  int
+	capacity int
+	lock     sync.Mutex
+	expiry
+}
+
+func NewLFUCache(capacity int, ttl time.Duration) Cache {
+	return &LFUCache{
+		store:    make(map[string]*lfuItem, capacity),
+		freqs:    make(map[int]*list.List),
+		capacity: capacity,
+		expiry:   expiry{ttl: ttl, stop: make(chan struct{})},
+	}
+}
+
+// Set
+func (lfu *LFUCache) Set(key string, value string) {
+	lfu.SetWithTTL(key, value, lfu.ttl)
+}
+
+// SetWithTTL
+func (lfu *LFUCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := lfu.deadline(ttl, lfu.removeExpired)
+
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	item, exists := lfu.store[key]
+	if exists {
+		item.value = value
+		item.expiresAt = expiresAt
+		lfu.touch(item)
+		return
+	}
+
+	if len(lfu.store) >= lfu.capacity {
+		lfu.evict()
+	}
+
+	item = &lfuItem{entry: entry{key: key, value: value, expiresAt: expiresAt}, freq: 1}
+	item.elem = lfu.list(1).PushFront(item)
+	lfu.store[key] = item
+	lfu.minFreq = 1
+}
+
+// Get
+func (lfu *LFUCache) Get(key string) (string, error) {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	item, exists := lfu.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	lfu.touch(item)
+	return item.value, nil
+}
+
+// Peek
+func (lfu *LFUCache) Peek(key string) (string, error) {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	item, exists := lfu.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	return item.value, nil
+}
+
+// Delete
+func (lfu *LFUCache) Delete(key string) {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	if item, exists := lfu.store[key]; exists {
+		lfu.remove(item)
+	}
+}
+
+// Len
+func (lfu *LFUCache) Len() int {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	return len(lfu.store)
+}
+
+// Purge
+func (lfu *LFUCache) Purge() {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	lfu.store = make(map[string]*lfuItem, lfu.capacity)
+	lfu.freqs = make(map[int]*list.List)
+	lfu.minFreq = 0
+}
+
+// lookup finds an item that has not expired, the caller holds the lock
+func (lfu *LFUCache) lookup(key string) (*lfuItem, bool) {
+	item, exists := lfu.store[key]
+	if !exists {
+		return nil, false
+	}
+
+	if item.expired(time.Now()) {
+		lfu.remove(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+// touch moves an item to the list of the next use count
+func (lfu *LFUCache) touch(item *lfuItem) {
+	lfu.unlink(item)
+	if lfu.minFreq == item.freq && lfu.freqs[item.freq] == nil {
+		lfu.minFreq++
+	}
+	item.freq++
+	item.elem = lfu.list(item.freq).PushFront(item)
+}
+
+func (lfu *LFUCache) evict() {
+	items, exists := lfu.freqs[lfu.minFreq]
+	if !exists {
+		// the least used items were deleted, look for the next use count
+		lfu.minFreq = 0
+		for freq := range lfu.freqs {
+			if lfu.minFreq == 0 || freq < lfu.minFreq {
+				lfu.minFreq = freq
+			}
+		}
+		items = lfu.freqs[lfu.minFreq]
+	}
+	if items == nil {
+		return
+	}
+	lfu.remove(items.Back().Value.(*lfuItem))
+}
+
+func (lfu *LFUCache) remove(item *lfuItem) {
+	lfu.unlink(item)
+	delete(lfu.store, item.key)
+}
+
+// unlink takes an item out of its list and drops the list when it becomes empty
+func (lfu *LFUCache) unlink(item *lfuItem) {
+	items := lfu.freqs[item.freq]
+	items.Remove(item.elem)
+	if items.Len() == 0 {
+		delete(lfu.freqs, item.freq)
+	}
+}
+
+func (lfu *LFUCache) list(freq int) *list.List {
+	items, exists := lfu.freqs[freq]
+	if !exists {
+		items = list.New()
+		lfu.freqs[freq] = items
+	}
+	return items
+}
+
+func (lfu *LFUCache) removeExpired(now time.Time) {
+	lfu.lock.Lock()
+	defer lfu.lock.Unlock()
+
+	for _, item := range lfu.store {
+		if item.expired(now) {
+			lfu.remove(item)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import "testing"
+
+func TestLFUEvictsLeastFrequent(t *testing.T) {
+
+	lfu := NewLFUCache(2, 0)
+
+	lfu.Set("a", "va")
+	lfu.Set("b", "vb")
+	lfu.Get("a")
+	lfu.Get("a")
+	lfu.Get("b")
+	lfu.Set("c", "vc")
+
+	if _, err := lfu.Peek("b"); err == nil {
+		t.Error("expected key b to be evicted")
+	}
+
+	// c was used once, a three times
+	lfu.Set("d", "vd")
+	if _, err := lfu.Peek("c"); err == nil {
+		t.Error("expected key c to be evicted")
+	}
+	if _, err := lfu.Peek("a"); err != nil {
+		t.Error("expected key a to exist")
+	}
+}
+
+func TestLFUTiesEvictLeastRecent(t *testing.T) {
+
+	lfu := NewLFUCache(3, 0)
+
+	lfu.Set("a", "va")
+	lfu.Set("b", "vb")
+	lfu.Set("c", "vc")
+	lfu.Get("a")
+	lfu.Get("b")
+	lfu.Get("c")
+	lfu.Set("d", "vd")
+	lfu.Get("d")
+
+	// every key was used twice, a the longest ago
+	lfu.Set("e", "ve")
+	if _, err := lfu.Peek("a"); err == nil {
+		t.Error("expected key a to be evicted")
+	}
+}
+
+func TestLFUDeleteLeastFrequent(t *testing.T) {
+
+	lfu := NewLFUCache(2, 0)
+
+	lfu.Set("a", "va")
+	lfu.Get("a")
+	lfu.Set("b", "vb")
+	lfu.Delete("b")
+
+	// the least used count has no items left, eviction has to look for the next one
+	lfu.Set("c", "vc")
+	lfu.Get("c")
+	lfu.Get("c")
+	lfu.Set("d", "vd")
+	lfu.Set("e", "ve")
+
+	if _, err := lfu.Peek("d"); err == nil {
+		t.Error("expected key d to be evicted")
+	}
+	for _, key := range []string{"c", "e"} {
+		if _, err := lfu.Peek(key); err != nil {
+			t.Errorf("expected key %v to exist", key)
+		}
+	}
+}
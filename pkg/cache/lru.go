@@ -4,13 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type LRUCacheItem struct {
-	key      string
-	value    string
-	previous *LRUCacheItem
-	next     *LRUCacheItem
+	key   string
+	value string
+	// zero if the item never expires
+	expiresAt time.Time
+	previous  *LRUCacheItem
+	next      *LRUCacheItem
 }
 
 type LRUCache struct {
@@ -20,6 +23,7 @@ type LRUCache struct {
 	capacity int
 	lock     sync.Mutex
 	//logger   logger.Logger
+	expiry
 }
 
 func NewLRUCacheItem(key string, value string) *LRUCacheItem {
@@ -32,12 +36,18 @@ func NewLRUCacheItem(key string, value string) *LRUCacheItem {
 }
 
 func NewLRUCache(capacity int) Cache {
+	return NewLRUCacheWithTTL(capacity, 0)
+}
+
+// NewLRUCacheWithTTL creates a cache whose items expire after ttl unless they are set with their own
+func NewLRUCacheWithTTL(capacity int, ttl time.Duration) Cache {
 	return &LRUCache{
 		store:    make(map[string]*LRUCacheItem, capacity),
 		head:     nil,
 		tail:     nil,
 		capacity: capacity,
 		//logger:   logger,
+		expiry: expiry{ttl: ttl, stop: make(chan struct{})},
 	}
 }
 
@@ -81,24 +91,32 @@ func (lru *LRUCache) moveToFrontOfQ(item *LRUCacheItem) {
 
 // Set
 func (lru *LRUCache) Set(key string, value string) {
+	lru.SetWithTTL(key, value, lru.ttl)
+}
+
+// SetWithTTL
+func (lru *LRUCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := lru.deadline(ttl, lru.removeExpired)
+
 	lru.lock.Lock()
 	defer lru.lock.Unlock()
 
 	item, exists := lru.store[key]
 	if exists {
 		item.value = value
+		item.expiresAt = expiresAt
 		lru.moveToFrontOfQ(item)
 		return
 	}
 
 	if len(lru.store) >= lru.capacity {
 		// evict tail
-		fmt.Println("item for eviction", lru.tail.key)
 		delete(lru.store, lru.tail.key)
 		lru.removeItemFromQ(lru.tail)
 	}
 
 	item = NewLRUCacheItem(key, value)
+	item.expiresAt = expiresAt
 	lru.addItemToFrontOfQ(item)
 	lru.store[key] = item
 }
@@ -108,14 +126,93 @@ func (lru *LRUCache) Get(key string) (string, error) {
 	lru.lock.Lock()
 	defer lru.lock.Unlock()
 
-	item, exists := lru.store[key]
+	item, exists := lru.lookup(key)
 	if !exists {
 		return "", errors.New("Key not found")
 	}
 
+	lru.moveToFrontOfQ(item)
 	return item.value, nil
 }
 
+// Peek
+func (lru *LRUCache) Peek(key string) (string, error) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+
+	item, exists := lru.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	return item.value, nil
+}
+
+// lookup finds an item that has not expired, the caller holds the lock
+func (lru *LRUCache) lookup(key string) (*LRUCacheItem, bool) {
+	item, exists := lru.store[key]
+	if !exists {
+		return nil, false
+	}
+
+	// expired items are removed lazily, the janitor only catches those nobody asks for
+	if item.expired(time.Now()) {
+		delete(lru.store, key)
+		lru.removeItemFromQ(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+// Delete
+func (lru *LRUCache) Delete(key string) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+
+	item, exists := lru.store[key]
+	if !exists {
+		return
+	}
+
+	delete(lru.store, key)
+	lru.removeItemFromQ(item)
+}
+
+// Len
+func (lru *LRUCache) Len() int {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+
+	return len(lru.store)
+}
+
+// Purge
+func (lru *LRUCache) Purge() {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+
+	lru.store = make(map[string]*LRUCacheItem, lru.capacity)
+	lru.head = nil
+	lru.tail = nil
+}
+
+func (lru *LRUCache) removeExpired(now time.Time) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+
+	for key, item := range lru.store {
+		if item.expired(now) {
+			delete(lru.store, key)
+			lru.removeItemFromQ(item)
+		}
+	}
+}
+
+func (item *LRUCacheItem) expired(now time.Time) bool {
+	return !item.expiresAt.IsZero() && !now.Before(item.expiresAt)
+}
+
 func (lru *LRUCache) PrintLRU() {
 	if lru.head != nil {
 		fmt.Println("cache head", lru.head.key)
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestLRUEviction(t *testing.T) {
@@ -48,6 +49,241 @@ func TestLRUEviction(t *testing.T) {
 
 }
 
+func TestLRUDelete(t *testing.T) {
+
+	lru := NewLRUCache(2)
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	lru.Delete("a")
+	lru.Delete("missing")
+
+	if _, err := lru.Get("a"); err == nil {
+		t.Error("expected deleted key to be gone")
+	}
+
+	// the freed slot is reused without evicting b
+	lru.Set("c", "vc")
+	for _, key := range []string{"b", "c"} {
+		if _, err := lru.Get(key); err != nil {
+			t.Errorf("expected key %v to exist", key)
+		}
+	}
+}
+
+func TestLRUGetPromotes(t *testing.T) {
+
+	lru := NewLRUCache(2)
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	// a is now the most recently used, so b is evicted instead
+	if _, err := lru.Get("a"); err != nil {
+		t.Error(err)
+	}
+	lru.Set("c", "vc")
+
+	if _, err := lru.Get("b"); err == nil {
+		t.Error("expected key b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := lru.Get(key); err != nil {
+			t.Errorf("expected key %v to exist", key)
+		}
+	}
+}
+
+func TestLRUSetPromotes(t *testing.T) {
+
+	lru := NewLRUCache(2)
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	lru.Set("a", "va2")
+	lru.Set("c", "vc")
+
+	if _, err := lru.Peek("b"); err == nil {
+		t.Error("expected key b to be evicted")
+	}
+	if val, err := lru.Peek("a"); err != nil || val != "va2" {
+		t.Errorf("expected va2 received %v, %v", val, err)
+	}
+}
+
+func TestLRUPeekDoesNotPromote(t *testing.T) {
+
+	lru := NewLRUCache(2)
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	val, err := lru.Peek("a")
+	if err != nil {
+		t.Error(err)
+	}
+	if val != "va" {
+		t.Errorf("expected %v received %v", "va", val)
+	}
+	lru.Set("c", "vc")
+
+	if _, err := lru.Peek("a"); err == nil {
+		t.Error("expected key a to be evicted")
+	}
+	if _, err := lru.Peek("missing"); err == nil {
+		t.Error("expected missing key to be reported")
+	}
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+
+	lru := NewLRUCache(3)
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	lru.Set("c", "vc")
+	lru.Get("a")
+	lru.Get("b")
+
+	// c is the least recently used, then a, then b
+	expected := []string{"c", "a", "b"}
+	for i, key := range []string{"d", "e", "f"} {
+		lru.Set(key, "v"+key)
+		if _, err := lru.Peek(expected[i]); err == nil {
+			t.Errorf("expected key %v to be evicted after setting %v", expected[i], key)
+		}
+		if lru.Len() != 3 {
+			t.Errorf("expected %v received %v", 3, lru.Len())
+		}
+	}
+}
+
+func TestLRULenAndPurge(t *testing.T) {
+
+	lru := NewLRUCache(3)
+
+	if lru.Len() != 0 {
+		t.Errorf("expected %v received %v", 0, lru.Len())
+	}
+
+	lru.Set("a", "va")
+	lru.Set("b", "vb")
+	lru.Set("a", "va2")
+	if lru.Len() != 2 {
+		t.Errorf("expected %v received %v", 2, lru.Len())
+	}
+
+	lru.Delete("a")
+	if lru.Len() != 1 {
+		t.Errorf("expected %v received %v", 1, lru.Len())
+	}
+
+	lru.Purge()
+	if lru.Len() != 0 {
+		t.Errorf("expected %v received %v", 0, lru.Len())
+	}
+	if _, err := lru.Get("b"); err == nil {
+		t.Error("expected purged key to be gone")
+	}
+
+	// the cache keeps working after a purge
+	for _, key := range []string{"a", "b", "c", "d"} {
+		lru.Set(key, "v"+key)
+	}
+	if lru.Len() != 3 {
+		t.Errorf("expected %v received %v", 3, lru.Len())
+	}
+	if _, err := lru.Get("a"); err == nil {
+		t.Error("expected key a to be evicted")
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+
+	lru := NewLRUCacheWithTTL(3, 20*time.Millisecond)
+	defer lru.Stop()
+
+	lru.Set("a", "va")
+	lru.SetWithTTL("b", "vb", time.Hour)
+	lru.SetWithTTL("c", "vc", 0)
+
+	if _, err := lru.Get("a"); err != nil {
+		t.Error("expected key a to exist before its TTL")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := lru.Get("a"); err == nil {
+		t.Error("expected key a to expire with the default TTL")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, err := lru.Get(key); err != nil {
+			t.Errorf("expected key %v to exist", key)
+		}
+	}
+}
+
+func TestLRUSetResetsExpiry(t *testing.T) {
+
+	lru := NewLRUCache(2)
+	defer lru.Stop()
+
+	lru.SetWithTTL("a", "va", 10*time.Millisecond)
+	lru.Set("a", "va2")
+
+	time.Sleep(20 * time.Millisecond)
+
+	if val, err := lru.Get("a"); err != nil || val != "va2" {
+		t.Errorf("expected va2 received %v, %v", val, err)
+	}
+}
+
+func TestLRUJanitor(t *testing.T) {
+
+	lru := NewLRUCache(2).(*LRUCache)
+
+	lru.SetWithTTL("a", "va", 10*time.Millisecond)
+	lru.Set("b", "vb")
+
+	// nobody asks for a, so only the janitor can remove it
+	deadline := time.Now().Add(3 * minJanitorInterval)
+	for {
+		lru.lock.Lock()
+		_, exists := lru.store["a"]
+		lru.lock.Unlock()
+		if !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the janitor to remove the expired key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := lru.Get("b"); err != nil {
+		t.Error("expected key b to exist")
+	}
+
+	lru.Stop()
+	// stopping twice is fine
+	lru.Stop()
+}
+
+func TestJanitorInterval(t *testing.T) {
+	tests := []struct {
+		defaultTTL, ttl, expected time.Duration
+	}{
+		{0, 10 * time.Millisecond, minJanitorInterval},
+		{0, 5 * time.Second, 5 * time.Second},
+		{0, time.Hour, maxJanitorInterval},
+		{30 * time.Second, 10 * time.Millisecond, 30 * time.Second},
+		{time.Hour, time.Second, maxJanitorInterval},
+	}
+	for _, test := range tests {
+		if interval := janitorInterval(test.defaultTTL, test.ttl); interval != test.expected {
+			t.Errorf("janitorInterval(%v, %v) = %v; want %v", test.defaultTTL, test.ttl, interval, test.expected)
+		}
+	}
+}
+
 func TestLRUConcurrency(t *testing.T) {
 	lru := NewLRUCache(26)
 	var wg sync.WaitGroup
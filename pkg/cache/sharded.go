@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"hash/maphash"
+	"time"
+)
+
+// ShardedCache spreads keys over independently locked caches, so concurrent redirects of different
+// links rarely wait for the same lock. Every shard evicts on its own with its share of the capacity,
+// so the policy only holds within a shard.
+type ShardedCache struct {
+	seed   maphash.Seed
+	shards []Cache
+}
+
+// NewShardedCache splits capacity over shards caches made by newShard
+func NewShardedCache(capacity int, shards int, newShard func(capacity int) Cache) Cache {
+	sharded := &ShardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]Cache, shards),
+	}
+	for i := range sharded.shards {
+		// the first shards take the remainder
+		shardCapacity := capacity / shards
+		if i < capacity%shards {
+			shardCapacity++
+		}
+		sharded.shards[i] = newShard(shardCapacity)
+	}
+	return sharded
+}
+
+func (sharded *ShardedCache) shard(key string) Cache {
+	return sharded.shards[maphash.String(sharded.seed, key)%uint64(len(sharded.shards))]
+}
+
+// Get
+func (sharded *ShardedCache) Get(key string) (string, error) {
+	return sharded.shard(key).Get(key)
+}
+
+// Peek
+func (sharded *ShardedCache) Peek(key string) (string, error) {
+	return sharded.shard(key).Peek(key)
+}
+
+// Set
+func (sharded *ShardedCache) Set(key string, value string) {
+	sharded.shard(key).Set(key, value)
+}
+
+// SetWithTTL
+func (sharded *ShardedCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	sharded.shard(key).SetWithTTL(key, value, ttl)
+}
+
+// Delete
+func (sharded *ShardedCache) Delete(key string) {
+	sharded.shard(key).Delete(key)
+}
+
+// Len adds up the shards, which are not locked together
+func (sharded *ShardedCache) Len() int {
+	n := 0
+	for _, shard := range sharded.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Purge
+func (sharded *ShardedCache) Purge() {
+	for _, shard := range sharded.shards {
+		shard.Purge()
+	}
+}
+
+// Stop
+func (sharded *ShardedCache) Stop() {
+	for _, shard := range sharded.shards {
+		shard.Stop()
+	}
+}
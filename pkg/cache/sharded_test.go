@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestShardedCache(t *testing.T) {
+
+	var capacities []int
+	NewShardedCache(10, 4, func(capacity int) Cache {
+		capacities = append(capacities, capacity)
+		return NewLRUCache(capacity)
+	})
+
+	// 10 over 4 shards, the first take the remainder
+	expected := []int{3, 3, 2, 2}
+	for i := range expected {
+		if capacities[i] != expected[i] {
+			t.Errorf("expected capacities %v received %v", expected, capacities)
+			break
+		}
+	}
+
+	// every shard has room for all keys, so nothing is evicted
+	sharded := NewShardedCache(40, 4, NewLRUCache)
+	defer sharded.Stop()
+
+	for i := 0; i < 10; i++ {
+		sharded.Set("key_"+strconv.Itoa(i), "value_"+strconv.Itoa(i))
+	}
+	for i := 0; i < 10; i++ {
+		if val, err := sharded.Get("key_" + strconv.Itoa(i)); err != nil || val != "value_"+strconv.Itoa(i) {
+			t.Errorf("expected value_%v received %v, %v", i, val, err)
+		}
+	}
+	if sharded.Len() != 10 {
+		t.Errorf("expected %v received %v", 10, sharded.Len())
+	}
+
+	sharded.Delete("key_0")
+	if _, err := sharded.Peek("key_0"); err == nil {
+		t.Error("expected deleted key to be gone")
+	}
+
+	sharded.Purge()
+	if sharded.Len() != 0 {
+		t.Errorf("expected %v received %v", 0, sharded.Len())
+	}
+}
+
+func TestShardedCacheCapacity(t *testing.T) {
+
+	sharded, err := NewCache(Options{Capacity: 64, Shards: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		sharded.Set("key_"+strconv.Itoa(i), "v")
+	}
+	if sharded.Len() != 64 {
+		t.Errorf("expected %v received %v", 64, sharded.Len())
+	}
+}
+
+func TestShardedCacheConcurrency(t *testing.T) {
+	sharded, err := NewCache(Options{Capacity: 26, Shards: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "key_" + strconv.Itoa(i%26)
+			sharded.Set(key, "value_"+strconv.Itoa(i))
+			sharded.Get(key)
+			sharded.Len()
+		}(i)
+	}
+
+	wg.Wait()
+}
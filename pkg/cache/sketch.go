@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// sketchDepth is the number of counter rows, an estimate is the smallest of its counters
+const sketchDepth = 4
+
+// sketchWidthRatio is the number of counters of a row per cached item
+const sketchWidthRatio = 4
+
+// sketchResetRatio is the number of increments per cached item after which the counts are halved
+const sketchResetRatio = 10
+
+// doorkeeper bits per key and bits set per key, about one in twenty unseen keys passes as seen
+const (
+	doorkeeperBitsPerKey = 8
+	doorkeeperHashes     = 2
+)
+
+// maxSketchCount is the largest count of a counter, TinyLFU only needs to tell hot from cold
+const maxSketchCount = 15
+
+// sketch is a count-min sketch of how often keys were used recently. All counts are halved after
+// every resetAfter increments, so links that stopped being popular make room for new ones. The first
+// use of a key only sets its bits in the doorkeeper bloom filter, so the many links that are used
+// once, like the ones a crawler reads, do not add to the counters the popular links share.
+type sketch struct {
+	seed           maphash.Seed
+	rows           [sketchDepth][]uint8
+	mask           uint64
+	doorkeeper     []uint64
+	doorkeeperMask uint64
+	additions      int
+	resetAfter     int
+}
+
+func newSketch(capacity int) *sketch {
+	// a power of two of a few counters per item keeps collisions with the tail low and the index a mask
+	width := 64
+	if counters := sketchWidthRatio * capacity; counters > width {
+		width = 1 << bits.Len(uint(counters-1))
+	}
+
+	resetAfter := sketchResetRatio * max(capacity, 1)
+	// the doorkeeper is cleared on every reset, so it holds at most resetAfter keys
+	doorkeeperBits := 1 << bits.Len(uint(doorkeeperBitsPerKey*resetAfter-1))
+
+	s := &sketch{
+		seed:           maphash.MakeSeed(),
+		mask:           uint64(width - 1),
+		doorkeeper:     make([]uint64, doorkeeperBits/64),
+		doorkeeperMask: uint64(doorkeeperBits - 1),
+		resetAfter:     resetAfter,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index of the counter of a key in row i
+func (s *sketch) index(hash uint64, i int) uint64 {
+	return rehash(hash, i) & s.mask
+}
+
+// doorkeeperBit is the i-th bit of a key in the doorkeeper
+func (s *sketch) doorkeeperBit(hash uint64, i int) uint64 {
+	return rehash(hash, sketchDepth+i) & s.doorkeeperMask
+}
+
+// rehash derives the i-th hash of a key with the splitmix64 finalizer, so two keys that share one
+// counter are unlikely to share the counters of the other rows
+func rehash(hash uint64, i int) uint64 {
+	h := hash + uint64(i+1)*0x9e3779b97f4a7c15
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
+
+func (s *sketch) increment(key string) {
+	hash := maphash.String(s.seed, key)
+	if s.admitted(hash) {
+		for i := range s.rows {
+			if counter := &s.rows[i][s.index(hash, i)]; *counter < maxSketchCount {
+				*counter++
+			}
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAfter {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	hash := maphash.String(s.seed, key)
+	count := uint8(maxSketchCount)
+	for i := range s.rows {
+		count = min(count, s.rows[i][s.index(hash, i)])
+	}
+	if s.seen(hash) && count < maxSketchCount {
+		count++
+	}
+	return count
+}
+
+// admitted reports whether the doorkeeper saw the key before, and lets it remember the key
+func (s *sketch) admitted(hash uint64) bool {
+	if s.seen(hash) {
+		return true
+	}
+	for i := 0; i < doorkeeperHashes; i++ {
+		bit := s.doorkeeperBit(hash, i)
+		s.doorkeeper[bit/64] |= 1 << (bit % 64)
+	}
+	return false
+}
+
+func (s *sketch) seen(hash uint64) bool {
+	for i := 0; i < doorkeeperHashes; i++ {
+		bit := s.doorkeeperBit(hash, i)
+		if s.doorkeeper[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	clear(s.doorkeeper)
+	s.additions /= 2
+}
+
+func (s *sketch) clear() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	clear(s.doorkeeper)
+	s.additions = 0
+}
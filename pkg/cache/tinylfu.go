@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// shares of the capacity of the W-TinyLFU segments, as used by Caffeine
+const (
+	tinyLFUWindowRatio    = 0.01
+	tinyLFUProtectedRatio = 0.8
+)
+
+// segments of the W-TinyLFU cache
+const (
+	tinyLFUWindow = iota
+	tinyLFUProbation
+	tinyLFUProtected
+)
+
+type tinyLFUItem struct {
+	entry
+	elem    *list.Element
+	segment int
+}
+
+// TinyLFUCache is the W-TinyLFU policy of Einziger, Friedman and Manes. New items enter a small LRU
+// window, and an item leaving the window only replaces the next victim of the main segmented LRU
+// if a sketch of recent use counts says it is used more often. Crawled links are used once and
+// never push out popular ones, while a burst of new traffic still gets through the window.
+type TinyLFUCache struct {
+	store map[string]*tinyLFUItem
+	// most recently used at the front, items used again move from probation to protected
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	frequency *sketch
+
+	capacity          int
+	windowCapacity    int
+	protectedCapacity int
+	lock              sync.Mutex
+	expiry
+}
+
+func NewTinyLFUCache(capacity int, ttl time.Duration) Cache {
+	windowCapacity := max(1, int(float64(capacity)*tinyLFUWindowRatio))
+	return &TinyLFUCache{
+		store:             make(map[string]*tinyLFUItem, capacity),
+		window:            list.New(),
+		probation:         list.New(),
+		protected:         list.New(),
+		frequency:         newSketch(capacity),
+		capacity:          capacity,
+		windowCapacity:    windowCapacity,
+		protectedCapacity: int(float64(capacity-windowCapacity) * tinyLFUProtectedRatio),
+		expiry:            expiry{ttl: ttl, stop: make(chan struct{})},
+	}
+}
+
+// Set
+func (c *TinyLFUCache) Set(key string, value string) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL
+func (c *TinyLFUCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := c.deadline(ttl, c.removeExpired)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.frequency.increment(key)
+
+	item, exists := c.store[key]
+	if exists {
+		item.value = value
+		item.expiresAt = expiresAt
+		c.touch(item)
+		return
+	}
+
+	item = &tinyLFUItem{entry: entry{key: key, value: value, expiresAt: expiresAt}, segment: tinyLFUWindow}
+	item.elem = c.window.PushFront(item)
+	c.store[key] = item
+
+	if c.window.Len() > c.windowCapacity {
+		c.admit(c.window.Back().Value.(*tinyLFUItem))
+	}
+}
+
+// Get
+func (c *TinyLFUCache) Get(key string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// misses count too, the item is usually set right after
+	c.frequency.increment(key)
+
+	item, exists := c.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	c.touch(item)
+	return item.value, nil
+}
+
+// Peek
+func (c *TinyLFUCache) Peek(key string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, exists := c.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	return item.value, nil
+}
+
+// Delete
+func (c *TinyLFUCache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if item, exists := c.store[key]; exists {
+		c.remove(item)
+	}
+}
+
+// Len
+func (c *TinyLFUCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.store)
+}
+
+// Purge
+func (c *TinyLFUCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.store = make(map[string]*tinyLFUItem, c.capacity)
+	c.window.Init()
+	c.probation.Init()
+	c.protected.Init()
+	c.frequency.clear()
+}
+
+// lookup finds an item that has not expired, the caller holds the lock
+func (c *TinyLFUCache) lookup(key string) (*tinyLFUItem, bool) {
+	item, exists := c.store[key]
+	if !exists {
+		return nil, false
+	}
+
+	if item.expired(time.Now()) {
+		c.remove(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+// touch marks an item as used, a probation item used again becomes protected
+func (c *TinyLFUCache) touch(item *tinyLFUItem) {
+	switch item.segment {
+	case tinyLFUWindow:
+		c.window.MoveToFront(item.elem)
+	case tinyLFUProtected:
+		c.protected.MoveToFront(item.elem)
+	case tinyLFUProbation:
+		c.probation.Remove(item.elem)
+		item.segment = tinyLFUProtected
+		item.elem = c.protected.PushFront(item)
+
+		if c.protected.Len() > c.protectedCapacity {
+			demoted := c.protected.Back().Value.(*tinyLFUItem)
+			c.protected.Remove(demoted.elem)
+			demoted.segment = tinyLFUProbation
+			demoted.elem = c.probation.PushFront(demoted)
+		}
+	}
+}
+
+// admit moves the candidate leaving the window into the main segments if it is used more often
+// than the item it would replace, otherwise the candidate is dropped
+func (c *TinyLFUCache) admit(candidate *tinyLFUItem) {
+	c.window.Remove(candidate.elem)
+
+	if len(c.store) > c.capacity {
+		victim := c.probation.Back()
+		if victim == nil {
+			victim = c.protected.Back()
+		}
+		if victim == nil {
+			// the window takes the whole capacity
+			delete(c.store, candidate.key)
+			return
+		}
+
+		victimItem := victim.Value.(*tinyLFUItem)
+		if c.frequency.estimate(candidate.key) <= c.frequency.estimate(victimItem.key) {
+			delete(c.store, candidate.key)
+			return
+		}
+		c.remove(victimItem)
+	}
+
+	candidate.segment = tinyLFUProbation
+	candidate.elem = c.probation.PushFront(candidate)
+}
+
+func (c *TinyLFUCache) remove(item *tinyLFUItem) {
+	switch item.segment {
+	case tinyLFUWindow:
+		c.window.Remove(item.elem)
+	case tinyLFUProbation:
+		c.probation.Remove(item.elem)
+	case tinyLFUProtected:
+		c.protected.Remove(item.elem)
+	}
+	delete(c.store, item.key)
+}
+
+func (c *TinyLFUCache) removeExpired(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, item := range c.store {
+		if item.expired(now) {
+			c.remove(item)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTinyLFUScanKeepsFrequent(t *testing.T) {
+
+	c := NewTinyLFUCache(100, 0)
+
+	// used often enough to max out their counters
+	for round := 0; round < 16; round++ {
+		for i := 0; i < 20; i++ {
+			key := "hot_" + strconv.Itoa(i)
+			if _, err := c.Get(key); err != nil {
+				c.Set(key, "v")
+			}
+		}
+	}
+
+	// four times the capacity, but within one aging period of the sketch
+	for i := 0; i < 400; i++ {
+		c.Set("crawled_"+strconv.Itoa(i), "v")
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := c.Peek("hot_" + strconv.Itoa(i)); err != nil {
+			t.Errorf("expected key hot_%v to exist", i)
+		}
+	}
+	if c.Len() != 100 {
+		t.Errorf("expected %v received %v", 100, c.Len())
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(100)
+
+	for i := 0; i < 5; i++ {
+		s.increment("a")
+	}
+	s.increment("b")
+
+	if count := s.estimate("a"); count < 5 {
+		t.Errorf("expected at least %v received %v", 5, count)
+	}
+	if count := s.estimate("b"); count < 1 || count >= s.estimate("a") {
+		t.Errorf("expected b to be estimated below a received %v", count)
+	}
+
+	for i := 0; i < 100; i++ {
+		s.increment("a")
+	}
+	if count := s.estimate("a"); count > maxSketchCount {
+		t.Errorf("expected at most %v received %v", maxSketchCount, count)
+	}
+
+	s.reset()
+	if count := s.estimate("a"); count > maxSketchCount/2 {
+		t.Errorf("expected the count to be halved received %v", count)
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// shares of the capacity of the 2Q queues, as suggested by the paper
+const (
+	twoQueueRecentRatio = 0.25
+	twoQueueGhostRatio  = 0.5
+)
+
+type twoQueueItem struct {
+	entry
+	elem *list.Element
+	// true in the main queue, false in the recent queue
+	frequent bool
+}
+
+// TwoQueueCache is the 2Q policy of Johnson and Shasha. New items wait in a small FIFO queue and
+// only reach the main LRU queue when they are set again after leaving it, which the ghost queue of
+// recently dropped keys remembers. A crawler reading every link once only churns the FIFO queue.
+type TwoQueueCache struct {
+	store map[string]*twoQueueItem
+	// recent FIFO queue and main LRU queue, newest at the front
+	recent   *list.List
+	frequent *list.List
+	// keys that recently left the recent queue
+	ghosts    map[string]*list.Element
+	ghostKeys *list.List
+
+	capacity       int
+	recentCapacity int
+	ghostCapacity  int
+	lock           sync.Mutex
+	expiry
+}
+
+func NewTwoQueueCache(capacity int, ttl time.Duration) Cache {
+	return &TwoQueueCache{
+		store:          make(map[string]*twoQueueItem, capacity),
+		recent:         list.New(),
+		frequent:       list.New(),
+		ghosts:         make(map[string]*list.Element),
+		ghostKeys:      list.New(),
+		capacity:       capacity,
+		recentCapacity: max(1, int(float64(capacity)*twoQueueRecentRatio)),
+		ghostCapacity:  max(1, int(float64(capacity)*twoQueueGhostRatio)),
+		expiry:         expiry{ttl: ttl, stop: make(chan struct{})},
+	}
+}
+
+// Set
+func (q *TwoQueueCache) Set(key string, value string) {
+	q.SetWithTTL(key, value, q.ttl)
+}
+
+// SetWithTTL
+func (q *TwoQueueCache) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := q.deadline(ttl, q.removeExpired)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	item, exists := q.store[key]
+	if exists {
+		item.value = value
+		item.expiresAt = expiresAt
+		if item.frequent {
+			q.frequent.MoveToFront(item.elem)
+		}
+		return
+	}
+
+	if len(q.store) >= q.capacity {
+		q.evict()
+	}
+
+	item = &twoQueueItem{entry: entry{key: key, value: value, expiresAt: expiresAt}}
+	if elem, seen := q.ghosts[key]; seen {
+		// set again soon after it left the recent queue, so it is used more than once
+		q.ghostKeys.Remove(elem)
+		delete(q.ghosts, key)
+		item.frequent = true
+		item.elem = q.frequent.PushFront(item)
+	} else {
+		item.elem = q.recent.PushFront(item)
+	}
+	q.store[key] = item
+}
+
+// Get
+func (q *TwoQueueCache) Get(key string) (string, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	item, exists := q.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	// hits in the recent queue are usually the same burst of requests, they do not count
+	if item.frequent {
+		q.frequent.MoveToFront(item.elem)
+	}
+	return item.value, nil
+}
+
+// Peek
+func (q *TwoQueueCache) Peek(key string) (string, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	item, exists := q.lookup(key)
+	if !exists {
+		return "", errors.New("Key not found")
+	}
+
+	return item.value, nil
+}
+
+// Delete
+func (q *TwoQueueCache) Delete(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if item, exists := q.store[key]; exists {
+		q.remove(item)
+	}
+}
+
+// Len
+func (q *TwoQueueCache) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.store)
+}
+
+// Purge
+func (q *TwoQueueCache) Purge() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.store = make(map[string]*twoQueueItem, q.capacity)
+	q.recent.Init()
+	q.frequent.Init()
+	q.ghosts = make(map[string]*list.Element)
+	q.ghostKeys.Init()
+}
+
+// lookup finds an item that has not expired, the caller holds the lock
+func (q *TwoQueueCache) lookup(key string) (*twoQueueItem, bool) {
+	item, exists := q.store[key]
+	if !exists {
+		return nil, false
+	}
+
+	if item.expired(time.Now()) {
+		q.remove(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+// evict frees one slot, from the recent queue while it is over its share
+func (q *TwoQueueCache) evict() {
+	if q.recent.Len() > 0 && (q.recent.Len() >= q.recentCapacity || q.frequent.Len() == 0) {
+		item := q.recent.Back().Value.(*twoQueueItem)
+		q.remove(item)
+		q.remember(item.key)
+		return
+	}
+
+	if q.frequent.Len() > 0 {
+		q.remove(q.frequent.Back().Value.(*twoQueueItem))
+	}
+}
+
+// remember adds a key to the ghost queue, forgetting the oldest one when it is full
+func (q *TwoQueueCache) remember(key string) {
+	q.ghosts[key] = q.ghostKeys.PushFront(key)
+	if q.ghostKeys.Len() > q.ghostCapacity {
+		oldest := q.ghostKeys.Back()
+		q.ghostKeys.Remove(oldest)
+		delete(q.ghosts, oldest.Value.(string))
+	}
+}
+
+func (q *TwoQueueCache) remove(item *twoQueueItem) {
+	if item.frequent {
+		q.frequent.Remove(item.elem)
+	} else {
+		q.recent.Remove(item.elem)
+	}
+	delete(q.store, item.key)
+}
+
+func (q *TwoQueueCache) removeExpired(now time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, item := range q.store {
+		if item.expired(now) {
+			q.remove(item)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTwoQueueRecentIsFIFO(t *testing.T) {
+
+	q := NewTwoQueueCache(4, 0)
+
+	q.Set("a", "va")
+	q.Set("b", "vb")
+	q.Set("c", "vc")
+	q.Set("d", "vd")
+	// hits in the recent queue do not keep a
+	q.Get("a")
+	q.Set("e", "ve")
+
+	if _, err := q.Peek("a"); err == nil {
+		t.Error("expected key a to be evicted")
+	}
+}
+
+func TestTwoQueueScanKeepsFrequent(t *testing.T) {
+
+	q := NewTwoQueueCache(8, 0)
+
+	// a leaves the recent queue and comes back, so it is used more than once
+	q.Set("a", "va")
+	for i := 0; i < 8; i++ {
+		q.Set("warmup_"+strconv.Itoa(i), "v")
+	}
+	if _, err := q.Peek("a"); err == nil {
+		t.Fatal("expected key a to be evicted")
+	}
+	q.Set("a", "va")
+
+	for i := 0; i < 100; i++ {
+		q.Set("crawled_"+strconv.Itoa(i), "v")
+	}
+
+	if val, err := q.Get("a"); err != nil || val != "va" {
+		t.Errorf("expected va received %v, %v", val, err)
+	}
+}